@@ -0,0 +1,282 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mksvc/unit"
+)
+
+type DeployCmd struct {
+	Binary string `arg:"" type:"existingfile" help:"New service executable."`
+
+	Config    string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml."`
+	Keep      int    `name:"keep" default:"5" help:"Number of releases to keep."`
+	NoRestart bool   `name:"no-restart" help:"Swap the executable without restarting the service."`
+}
+
+type RollbackCmd struct {
+	Config    string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml."`
+	To        string `name:"to" placeholder:"RELEASE" help:"Release to roll back to (default: the previous one)."`
+	NoRestart bool   `name:"no-restart" help:"Swap the executable without restarting the service."`
+}
+
+func (cmd *DeployCmd) Run(cli *CLI) error {
+	if cmd.Keep < 1 {
+		return fmt.Errorf("--keep must be at least 1")
+	}
+
+	cfg, err := loadServiceConfig(cli, cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Backend == "quadlet" {
+		return fmt.Errorf("the quadlet backend runs %s; deploy a new image tag instead", cfg.Image)
+	}
+
+	data, err := os.ReadFile(cmd.Binary)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return fmt.Errorf("refusing to deploy empty executable %s", cmd.Binary)
+	}
+
+	sum := checksum(data)
+	executable := filepath.Join(cfg.Path, cfg.Name)
+	dir := filepath.Join(cfg.Path, unit.ReleaseDir)
+
+	current, err := os.ReadFile(executable)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if current != nil && checksum(current) == sum {
+		log.Printf("%s is already deployed.\n", cmd.Binary)
+
+		return nil
+	}
+
+	id := unit.ReleaseID(time.Now(), sum)
+
+	if cli.DryRun {
+		log.Printf("Would deploy %s as release %s\n", cmd.Binary, id)
+		log.Printf("Would replace %s and keep %d releases in %s\n", executable, cmd.Keep, dir)
+		log.Println("Dry run - no files written.")
+
+		return nil
+	}
+
+	err = ensureReleaseDir(dir)
+	if err != nil {
+		return err
+	}
+
+	releases, err := unit.ListReleases(dir)
+	if err != nil {
+		return err
+	}
+
+	// Executables installed before the first deploy are kept so they can be
+	// rolled back to
+	if current != nil && unit.FindRelease(releases, checksum(current)) == nil {
+		previous := unit.ReleaseID(time.Now().Add(-time.Second), checksum(current))
+
+		err = storeRelease(dir, previous, cfg.Name, current)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Archived current executable as release %s\n", previous)
+	}
+
+	err = storeRelease(dir, id, cfg.Name, data)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Stored release %s\n", id)
+
+	err = activateRelease(cfg, executable, data, !cmd.NoRestart)
+	if err != nil {
+		return err
+	}
+
+	removed, err := unit.PruneReleases(dir, cmd.Keep, sum)
+
+	for _, id := range removed {
+		log.Printf("Removed release %s\n", id)
+	}
+
+	return err
+}
+
+func (cmd *RollbackCmd) Run(cli *CLI) error {
+	cfg, err := loadServiceConfig(cli, cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Backend == "quadlet" {
+		return fmt.Errorf("the quadlet backend runs %s; switch back to an older image tag instead", cfg.Image)
+	}
+
+	executable := filepath.Join(cfg.Path, cfg.Name)
+	dir := filepath.Join(cfg.Path, unit.ReleaseDir)
+
+	releases, err := unit.ListReleases(dir)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(executable)
+	if err != nil {
+		return err
+	}
+
+	sum := checksum(current)
+
+	target, err := unit.RollbackTarget(releases, sum, cmd.To)
+	if err != nil {
+		return err
+	}
+
+	if target.Sum == sum[:12] {
+		log.Printf("Release %s is already active.\n", target.ID)
+
+		return nil
+	}
+
+	if cli.DryRun {
+		log.Printf("Would roll back %s to release %s\n", executable, target.ID)
+		log.Println("Dry run - no files written.")
+
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(target.Path, cfg.Name))
+	if err != nil {
+		return err
+	}
+
+	if checksum(data)[:12] != target.Sum {
+		return fmt.Errorf("release %s does not match its checksum", target.ID)
+	}
+
+	log.Printf("Rolling back to release %s\n", target.ID)
+
+	return activateRelease(cfg, executable, data, !cmd.NoRestart)
+}
+
+func loadServiceConfig(cli *CLI, config string) (*unit.ServiceConfig, error) {
+	source := configPath
+
+	if config != "" {
+		source = config
+	}
+
+	cfg, err := unit.LoadConfig(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not load config: %w", err)
+	}
+
+	if len(cli.AllowedRoots) > 0 {
+		cfg.AllowedRoots = cli.AllowedRoots
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cli.DryRun && !cfg.UserMode() && os.Geteuid() != 0 {
+		return nil, fmt.Errorf("run this command as root")
+	}
+
+	info, err := os.Lstat(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("service path must be an existing, real directory: %s", cfg.Path)
+	}
+
+	return cfg, nil
+}
+
+func activateRelease(cfg *unit.ServiceConfig, executable string, data []byte, restart bool) error {
+	info, err := os.Lstat(executable)
+	if err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("refusing to replace unsafe service executable %s", executable)
+	}
+
+	err = unit.WriteFileAtomic(executable, data, 0755)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Activated %s\n", executable)
+
+	if !restart {
+		return nil
+	}
+
+	log.Printf("Restarting %s...\n", cfg.Name)
+
+	err = serviceAction(cfg, unit.ActionRestart)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Done.")
+
+	return nil
+}
+
+func ensureReleaseDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return os.Mkdir(dir, 0700)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("releases path must be a real directory: %s", dir)
+	}
+
+	if os.Geteuid() == 0 {
+		err = os.Chown(dir, 0, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Chmod(dir, 0700)
+}
+
+func storeRelease(dir, id, name string, data []byte) error {
+	path := filepath.Join(dir, id)
+
+	err := os.Mkdir(path, 0700)
+	if err != nil {
+		return err
+	}
+
+	return unit.WriteFileAtomic(filepath.Join(path, name), data, 0755)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"mksvc/unit"
+)
+
+type DepsCmd struct {
+	Configs []string `arg:"" optional:"" placeholder:"FILE" help:"Service configurations; a file may hold several services as YAML documents separated by '---' (default: conf/svc.yml)."`
+}
+
+func (cmd *DepsCmd) Run(cli *CLI) error {
+	sources := cmd.Configs
+
+	if len(sources) == 0 {
+		sources = []string{configPath}
+	}
+
+	var cfgs []*unit.ServiceConfig
+
+	for _, source := range sources {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("could not load config: %w", err)
+		}
+
+		parsed, err := unit.ParseConfigs(data)
+		if err != nil {
+			return fmt.Errorf("could not load %s: %w", source, err)
+		}
+
+		cfgs = append(cfgs, parsed...)
+	}
+
+	order, err := unit.DependencyOrder(cfgs)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Start order:")
+
+	for i, cfg := range order {
+		var details []string
+
+		for _, dependency := range []struct {
+			label string
+			units []string
+		}{
+			{"requires", cfg.DependsOnUnits()},
+			{"wants", cfg.WantsUnits()},
+			{"bound to", cfg.BindsToUnits()},
+			{"before", cfg.BeforeUnits()},
+		} {
+			if len(dependency.units) > 0 {
+				details = append(details, dependency.label+" "+strings.Join(dependency.units, " "))
+			}
+		}
+
+		if len(details) == 0 {
+			log.Printf("  %d. %s\n", i+1, cfg.Name)
+		} else {
+			log.Printf("  %d. %s (%s)\n", i+1, cfg.Name, strings.Join(details, "; "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/gosym"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mksvc/unit"
+)
+
+var (
+	goNetworkFuncs = []string{
+		"net.socket",
+		"net.(*Dialer).DialContext",
+	}
+
+	goListenFuncs = []string{
+		"net.(*ListenConfig).Listen",
+		"net.(*ListenConfig).ListenPacket",
+		"net.(*sysListener).listenTCP",
+		"net.(*sysListener).listenUnix",
+	}
+
+	goSubprocessFuncs = []string{
+		"os.StartProcess",
+		"os/exec.(*Cmd).Start",
+		"syscall.forkExec",
+	}
+
+	jitModules = map[string]string{
+		"github.com/tetratelabs/wazero":           "embeds wazero (WASM compiler)",
+		"github.com/wasmerio/wasmer-go":           "embeds wasmer (WASM compiler)",
+		"github.com/bytecodealliance/wasmtime-go": "embeds wasmtime (WASM compiler)",
+	}
+
+	jitLibraries = map[string]string{
+		"libnode":     "links libnode (V8 JIT)",
+		"libv8":       "links libv8 (JIT)",
+		"libjvm":      "links libjvm (JVM JIT)",
+		"libmono":     "links libmono (.NET JIT)",
+		"libcoreclr":  "links libcoreclr (.NET JIT)",
+		"libluajit":   "links libluajit (JIT)",
+		"libwasmtime": "links libwasmtime (WASM compiler)",
+	}
+
+	jitInterpreters = map[string]string{
+		"node": "runs under node (V8 JIT)",
+		"deno": "runs under deno (V8 JIT)",
+		"bun":  "runs under bun (JIT)",
+		"java": "runs under java (JVM JIT)",
+	}
+
+	networkSymbols    = []string{"socket", "connect", "getaddrinfo", "gethostbyname"}
+	listenSymbols     = []string{"bind", "listen", "accept", "accept4"}
+	subprocessSymbols = []string{"fork", "vfork", "execve", "execv", "execvp", "execvpe", "posix_spawn", "posix_spawnp", "system", "popen"}
+	execMemorySymbols = []string{"mprotect", "pkey_mprotect"}
+)
+
+type Detection struct {
+	Kind string
+
+	// Evidence per option (empty = no indication found)
+	Network    []string
+	Listening  []string
+	ExecMemory []string
+	Subprocess []string
+}
+
+func DetectExecutable(path string) (*Detection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	header := make([]byte, 128)
+
+	n, _ := file.Read(header)
+	header = header[:n]
+
+	det := &Detection{}
+
+	if bytes.HasPrefix(header, []byte("#!")) {
+		det.inspectScript(header)
+
+		return det, nil
+	}
+
+	binary, err := elf.NewFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an ELF executable or script", path)
+	}
+
+	defer binary.Close()
+
+	libs, _ := binary.ImportedLibraries()
+
+	info, err := buildinfo.ReadFile(path)
+	if err == nil {
+		det.Kind = "Go " + info.GoVersion + " binary"
+
+		det.inspectGo(binary, info)
+	} else {
+		det.Kind = "ELF binary"
+	}
+
+	if len(libs) > 0 {
+		det.Kind += fmt.Sprintf(", dynamically linked (%d libraries)", len(libs))
+
+		det.inspectDynamic(binary, libs)
+	} else {
+		det.Kind += ", statically linked"
+	}
+
+	if len(det.Listening) > 0 && len(det.Network) == 0 {
+		det.Network = append(det.Network, "listens on sockets")
+	}
+
+	return det, nil
+}
+
+func (det *Detection) Apply(cfg *unit.ServiceConfig) {
+	cfg.Network = len(det.Network) > 0
+	cfg.Listening = len(det.Listening) > 0
+	cfg.ExecMemory = len(det.ExecMemory) > 0
+	cfg.Subprocess = len(det.Subprocess) > 0
+}
+
+func (det *Detection) inspectScript(header []byte) {
+	line, _, _ := bufio.NewReader(bytes.NewReader(header)).ReadLine()
+
+	interpreter := unit.ScriptInterpreter(string(line))
+	if interpreter == "" {
+		det.Kind = "script"
+
+		return
+	}
+
+	det.Kind = "script (" + interpreter + ")"
+
+	if reason, ok := jitInterpreters[interpreter]; ok {
+		det.ExecMemory = append(det.ExecMemory, reason)
+	}
+}
+
+func (det *Detection) inspectGo(binary *elf.File, info *buildinfo.BuildInfo) {
+	for _, dep := range info.Deps {
+		if reason, ok := jitModules[dep.Path]; ok {
+			det.ExecMemory = append(det.ExecMemory, reason)
+		}
+	}
+
+	funcs := goFunctions(binary)
+
+	det.Network = appendCalls(det.Network, funcs, goNetworkFuncs)
+	det.Listening = appendCalls(det.Listening, funcs, goListenFuncs)
+	det.Subprocess = appendCalls(det.Subprocess, funcs, goSubprocessFuncs)
+}
+
+func (det *Detection) inspectDynamic(binary *elf.File, libs []string) {
+	for _, lib := range libs {
+		base := strings.SplitN(lib, ".so", 2)[0]
+
+		if reason, ok := jitLibraries[base]; ok {
+			det.ExecMemory = append(det.ExecMemory, reason)
+		}
+	}
+
+	symbols, _ := binary.ImportedSymbols()
+
+	imported := make(map[string]bool, len(symbols))
+
+	for _, sym := range symbols {
+		imported[sym.Name] = true
+	}
+
+	det.Network = appendImports(det.Network, imported, networkSymbols)
+	det.Listening = appendImports(det.Listening, imported, listenSymbols)
+	det.Subprocess = appendImports(det.Subprocess, imported, subprocessSymbols)
+	det.ExecMemory = appendImports(det.ExecMemory, imported, execMemorySymbols)
+}
+
+func goFunctions(binary *elf.File) map[string]bool {
+	pclntab := binary.Section(".gopclntab")
+	text := binary.Section(".text")
+
+	if pclntab == nil || text == nil {
+		return nil
+	}
+
+	data, err := pclntab.Data()
+	if err != nil {
+		return nil
+	}
+
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return nil
+	}
+
+	funcs := make(map[string]bool, len(table.Funcs))
+
+	for _, fn := range table.Funcs {
+		funcs[fn.Name] = true
+	}
+
+	return funcs
+}
+
+func appendCalls(reasons []string, funcs map[string]bool, names []string) []string {
+	for _, name := range names {
+		if funcs[name] {
+			reasons = append(reasons, "calls "+name)
+		}
+	}
+
+	return reasons
+}
+
+func appendImports(reasons []string, imported map[string]bool, names []string) []string {
+	for _, name := range names {
+		if imported[name] {
+			reasons = append(reasons, "imports "+name)
+		}
+	}
+
+	return reasons
+}
+
+func detectInto(cfg *unit.ServiceConfig) {
+	executable := filepath.Join(cfg.Path, cfg.Name)
+
+	det, err := DetectExecutable(executable)
+	if err != nil {
+		log.Printf("Skipping capability detection: %v\n", err)
+
+		return
+	}
+
+	det.Apply(cfg)
+
+	log.Printf("Detected %s, using suggestions as defaults.\n", det.Kind)
+}
+
+type DetectCmd struct {
+	Target
+}
+
+func (cmd *DetectCmd) Run(cli *CLI) error {
+	cfg, _ := loadTarget(cli, cmd.Target)
+
+	executable := filepath.Join(cfg.Path, cfg.Name)
+
+	det, err := DetectExecutable(executable)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Inspected %s (%s)\n", executable, det.Kind)
+	log.Println()
+
+	flags := []string{"mksvc", cfg.Name, cfg.Path}
+
+	for _, option := range []struct {
+		title    string
+		flag     string
+		evidence []string
+	}{
+		{"Network", "network", det.Network},
+		{"Listening", "listening", det.Listening},
+		{"ExecMemory", "exec-memory", det.ExecMemory},
+		{"Subprocess", "subprocess", det.Subprocess},
+	} {
+		if len(option.evidence) == 0 {
+			log.Printf("  %-12s no\n", option.title+":")
+
+			flags = append(flags, "--no-"+option.flag)
+
+			continue
+		}
+
+		log.Printf("  %-12s yes (%s)\n", option.title+":", strings.Join(option.evidence, ", "))
+
+		flags = append(flags, "--"+option.flag)
+	}
+
+	log.Println()
+	log.Println("Suggested:")
+	log.Printf("  %s\n", strings.Join(flags, " "))
+
+	return nil
+}
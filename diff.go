@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"mksvc/unit"
+)
+
+type DiffCmd struct {
+	Target
+
+	Config  string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml ('-' for stdin)."`
+	Against string `name:"against" placeholder:"FILE" help:"Unit file to compare with (default: the installed unit)."`
+}
+
+func (cmd *DiffCmd) Run(cli *CLI) error {
+	cfg, _ := loadTargetFrom(cli, cmd.Target, cmd.Config)
+
+	_, err := prepareConfig(cfg, cli)
+	if err != nil {
+		return err
+	}
+
+	data, err := cfg.RenderArtifact("service")
+	if err != nil {
+		return err
+	}
+
+	generated, err := unit.ParseUnitFile(data)
+	if err != nil {
+		return fmt.Errorf("could not parse generated unit: %w", err)
+	}
+
+	against := cmd.Against
+
+	if against == "" {
+		against = cfg.InstalledFiles()["unit"]
+	}
+
+	data, err = os.ReadFile(against)
+	if err != nil {
+		return err
+	}
+
+	existing, err := unit.ParseUnitFile(data)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", against, err)
+	}
+
+	changes := unit.DiffUnits(existing, generated)
+
+	if len(changes) == 0 {
+		log.Printf("%s matches the generated unit.\n", against)
+
+		return nil
+	}
+
+	log.Printf("Changes from %s to the generated unit:\n", against)
+
+	for _, change := range changes {
+		for _, value := range change.Old {
+			log.Printf("  - [%s] %s=%s\n", change.Section, change.Key, value)
+		}
+
+		for _, value := range change.New {
+			log.Printf("  + [%s] %s=%s\n", change.Section, change.Key, value)
+		}
+	}
+
+	return nil
+}
@@ -6,10 +6,10 @@ require (
 	github.com/alecthomas/kong v1.13.0
 	github.com/coalaura/plain v1.1.8
 	github.com/goccy/go-yaml v1.19.2
+	golang.org/x/term v0.39.0
 )
 
 require (
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
 )
@@ -2,8 +2,11 @@ package main
 
 import (
 	_ "embed"
+	"fmt"
 	"os"
 	"text/template"
+
+	"mksvc/unit"
 )
 
 //go:embed templates/help.tmpl
@@ -16,9 +19,24 @@ func version() {
 func help() {
 	t := template.Must(template.New("help").Parse(HelpStr))
 
-	t.Execute(os.Stdout, map[string]string{
+	presets, err := unit.LoadPresets(presetDirs...)
+	if err != nil {
+		log.Warnf("Could not load custom presets: %v\n", err)
+
+		presets, _ = unit.LoadPresets()
+	}
+
+	var list []string
+
+	for _, name := range unit.PresetNames(presets) {
+		list = append(list, fmt.Sprintf("%-18s %s", name, presets[name].Description))
+	}
+
+	t.Execute(os.Stdout, map[string]any{
 		"U": "\033[4m",
 		"B": "\033[1m",
 		"R": "\033[0m",
+
+		"Presets": list,
 	})
 }
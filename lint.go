@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"mksvc/unit"
+)
+
+type LintCmd struct {
+	Target
+
+	Config string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml ('-' for stdin)."`
+	Unit   string `name:"unit" placeholder:"FILE" help:"Unit file to lint (default: conf/<name>.service)."`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func (cmd *LintCmd) Run(cli *CLI) error {
+	if cli.Format != "text" && cli.Format != "sarif" {
+		return fmt.Errorf("lint supports --format=text or --format=sarif, not %s", cli.Format)
+	}
+
+	cfg, _ := loadTargetFrom(cli, cmd.Target, cmd.Config)
+
+	applyOverrides(cfg, cli)
+
+	// Preservation would read the file under review, so render without it
+	_, err := cfg.Prepare("")
+	if err != nil {
+		return err
+	}
+
+	path := cmd.Unit
+
+	if path == "" {
+		path = filepath.Join(confDir, cfg.Name+".service")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	current, err := unit.ParseUnitFile(data)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	findings, err := cfg.Lint(current)
+	if err != nil {
+		return err
+	}
+
+	if cli.Format == "sarif" {
+		err = printSARIF(path, findings)
+		if err != nil {
+			return err
+		}
+	} else {
+		printFindings(path, findings)
+	}
+
+	var errors int
+
+	for _, finding := range findings {
+		if finding.Severity == unit.SeverityError {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("%s weakens the generated sandbox (%d errors)", path, errors)
+	}
+
+	return nil
+}
+
+func printFindings(path string, findings []unit.LintFinding) {
+	if len(findings) == 0 {
+		log.Printf("%s matches the generated hardening.\n", path)
+
+		return
+	}
+
+	log.Printf("Linting %s:\n", path)
+
+	for _, finding := range findings {
+		log.Printf("  %-8s line %-4d %s\n", finding.Severity, finding.Line, finding.Message)
+	}
+}
+
+func printSARIF(path string, findings []unit.LintFinding) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "mksvc",
+				Version:        Version,
+				InformationURI: "https://github.com/coalaura/mksvc",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+
+	for _, finding := range findings {
+		if !rules[finding.Key] {
+			rules[finding.Key] = true
+
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID: finding.Key,
+				ShortDescription: sarifMessage{
+					Text: fmt.Sprintf("Hardening of [%s] %s", finding.Section, finding.Key),
+				},
+			})
+		}
+
+		location := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{
+					URI: filepath.ToSlash(path),
+				},
+			},
+		}
+
+		if finding.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{
+				StartLine: finding.Line,
+			}
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID: finding.Key,
+			Level:  finding.Severity,
+			Message: sarifMessage{
+				Text: finding.Message,
+			},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	report := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	_, err = os.Stdout.Write(data)
+
+	return err
+}
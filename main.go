@@ -1,26 +1,54 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/coalaura/plain"
+	"github.com/goccy/go-yaml"
+
+	"mksvc/unit"
 )
 
 var Version = "dev"
 
 var log = plain.New()
 
+const confDir = "conf"
+
+var (
+	configPath = filepath.Join(confDir, "svc.yml")
+	presetDirs = initPresetDirs()
+)
+
 // gost:preserve-layout
 type CLI struct {
-	Name string `arg:"" optional:"" help:"Name of the service and executable."`
-	Path string `arg:"" optional:"" help:"Path to the service root directory."`
-
-	Interactive bool `short:"i" help:"Enable interactive configuration mode."`
-	DryRun      bool `short:"n" name:"dry-run" help:"Preview generated files without writing."`
+	Generate GenerateCmd `cmd:"" default:"withargs" hidden:"" help:"Generate service configuration."`
+	Detect   DetectCmd   `cmd:"" help:"Suggest options by inspecting the service executable."`
+	Render   RenderCmd   `cmd:"" help:"Render a single artifact to stdout."`
+	Diff     DiffCmd     `cmd:"" help:"Compare an installed unit with the generated one."`
+	Lint     LintCmd     `cmd:"" help:"Check a unit file for hardening regressions."`
+	Deploy   DeployCmd   `cmd:"" help:"Install a new service executable as a release."`
+	Rollback RollbackCmd `cmd:"" help:"Switch back to the previous release."`
+	Portable PortableCmd `cmd:"" help:"Build a portable service image for portablectl."`
+	Restore  RestoreCmd  `cmd:"" help:"Reinstate files saved by setup.sh."`
+	Deps     DepsCmd     `cmd:"" help:"Check dependencies between services and print their start order."`
+	Notify   NotifyCmd   `cmd:"" help:"Report a failed unit (run by mksvc-notify@.service)."`
+	Migrate  MigrateCmd  `cmd:"" help:"Upgrade svc.yml to the current schema version."`
+	Schema   SchemaCmd   `cmd:"" help:"Print the JSON Schema for svc.yml."`
+
+	Interactive bool   `short:"i" help:"Enable interactive configuration mode."`
+	DryRun      bool   `short:"n" name:"dry-run" help:"Preview generated files without writing."`
+	Format      string `name:"format" enum:"text,json,yaml,sarif" default:"text" help:"Output format (text, json, yaml for dry runs; sarif for lint)."`
+	Preset      string `short:"p" name:"preset" help:"Start from a named option preset."`
+	Backend     string `name:"backend" help:"Init system to generate for (systemd, systemd-user, openrc, quadlet)."`
+	Image       string `name:"image" help:"Container image for the quadlet backend."`
+	UserMode    bool   `name:"user-mode" help:"Generate a systemd user service (same as --backend=systemd-user)."`
 
 	// Core options
 	Network         *bool  `name:"network" negatable:"" help:"Network access."`
@@ -47,21 +75,58 @@ type CLI struct {
 	// Environment
 	EnvFile string `name:"env-file" help:"Path to environment file."`
 
+	// Identity
+	Groups []string `name:"groups" help:"Comma-separated supplementary groups to join."`
+
+	// Dependencies
+	DependsOn []string `name:"depends-on" help:"Units to start after and require (bare names mean <name>.service)."`
+	Wants     []string `name:"wants" help:"Units to start after and want, without failing if they are missing."`
+	BindsTo   []string `name:"binds-to" help:"Units to start after and stop together with."`
+	Before    []string `name:"before" help:"Units that start after this service."`
+
+	// Policy
+	AllowedRoots []string `name:"allowed-roots" env:"MKSVC_ALLOWED_ROOTS" help:"Comma-separated directories services may live below."`
+
 	Help    bool `short:"h" help:"Show detailed help."`
 	Version bool `short:"v" help:"Print version."`
 }
 
-func main() {
-	go log.WaitForInterrupt(true)
+type Target struct {
+	Name string `arg:"" optional:"" help:"Name of the service and executable."`
+	Path string `arg:"" optional:"" help:"Path to the service root directory."`
+}
+
+type GenerateCmd struct {
+	Target
+}
+
+type RenderCmd struct {
+	Artifact string `arg:"" enum:"service,sysusers,logrotate,setup,uninstall,udev,notify-unit,notify-env,initd,confd,container,seccomp" help:"Artifact to render (service, sysusers, logrotate, setup, uninstall, udev, notify-unit, notify-env; initd, confd for openrc; container, seccomp for quadlet)."`
+
+	Target
+
+	Config string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml ('-' for stdin)."`
+}
 
+func main() {
 	var cli CLI
 
-	kong.Parse(&cli,
+	ctx := kong.Parse(&cli,
 		kong.Name("mksvc"),
 		kong.Description("Hardened systemd service generator"),
 		kong.NoDefaultHelp(),
+		kong.Bind(&cli),
 	)
 
+	if cli.Format != "text" || strings.HasPrefix(ctx.Command(), "render") {
+		// Keep stdout clean for machine-readable output
+		log = plain.New(plain.WithTarget(os.Stderr))
+	}
+
+	go log.WaitForInterrupt(true)
+
+	unit.SchemaURL = schemaURL()
+
 	if cli.Version {
 		version()
 
@@ -74,87 +139,209 @@ func main() {
 		return
 	}
 
-	confDir := "conf"
-	configPath := filepath.Join(confDir, "svc.yml")
+	log.MustExit(ctx.Run())
+}
 
-	cfg, err := LoadConfig(configPath)
-	if err != nil && !os.IsNotExist(err) {
-		log.MustExit(fmt.Errorf("could not load config: %w", err))
+func (cmd *GenerateCmd) Run(cli *CLI) error {
+	if cli.Format == "sarif" {
+		return fmt.Errorf("--format=sarif is only supported by lint")
+	}
+
+	if cli.Format != "text" && !cli.DryRun {
+		return fmt.Errorf("--format=%s requires --dry-run", cli.Format)
 	}
 
-	if cfg != nil {
-		log.Printf("Loaded existing configuration from %s\n", configPath)
+	cfg, loaded := loadTarget(cli, cmd.Target)
 
-		if cli.Name == "" {
-			cli.Name = cfg.Name
+	if !loaded && cli.Interactive {
+		detectInto(cfg)
+	}
+
+	if cli.Preset != "" {
+		preset, err := unit.FindPreset(cli.Preset, presetDirs...)
+		if err != nil {
+			return err
 		}
 
-		if cli.Path == "" {
-			cli.Path = cfg.Path
+		preset.Apply(cfg)
+
+		log.Printf("Applied preset %s\n", cli.Preset)
+	}
+
+	if cli.Interactive {
+		err := runInteractive(cfg)
+		if err != nil {
+			return err
 		}
 	}
 
-	if cli.Name == "" || cli.Path == "" {
-		log.Println("Usage: mksvc <name> <path> [options]")
-		log.Println("Run 'mksvc -h' for detailed help.")
+	warnings, err := prepareConfig(cfg, cli)
+	if err != nil {
+		return err
+	}
 
-		os.Exit(1)
+	artifacts, err := cfg.Artifacts(confDir)
+	if err != nil {
+		return err
 	}
 
-	if cfg == nil {
-		cfg = NewServiceConfig(cli.Name, cli.Path)
-	} else {
-		cfg.Name = cleanServiceName(cli.Name)
-		cfg.Path = cli.Path
+	if cli.DryRun {
+		if cli.Format != "text" {
+			return dryRunReport(cfg, artifacts, warnings, cli.Format)
+		}
 
-		cfg.UpdateLabel()
+		dryRun(cfg, artifacts, warnings)
+
+		return nil
 	}
 
-	if cli.Interactive {
-		runInteractive(cfg)
+	err = writeConfigs(confDir, artifacts)
+	if err != nil {
+		return err
 	}
 
-	applyOverrides(cfg, &cli)
+	if cfg.UserMode() {
+		log.Println("Done. Run 'bash conf/setup.sh' to install.")
+	} else {
+		log.Println("Done. Run 'sudo bash conf/setup.sh' to install.")
+	}
 
-	cfg.Normalize()
+	return nil
+}
 
-	if cfg.PrivateUsers {
-		allowed, _ := cfg.CanHavePrivateUsers()
+func (cmd *RenderCmd) Run(cli *CLI) error {
+	cfg, _ := loadTargetFrom(cli, cmd.Target, cmd.Config)
 
-		if !allowed {
-			cfg.PrivateUsers = false
-		}
+	_, err := prepareConfig(cfg, cli)
+	if err != nil {
+		return err
 	}
 
-	err = cfg.Validate()
+	data, err := cfg.RenderArtifact(cmd.Artifact)
 	if err != nil {
-		log.MustExit(err)
+		return err
 	}
 
-	servicePath := filepath.Join(confDir, cfg.Name+".service")
+	_, err = os.Stdout.Write(data)
+
+	return err
+}
 
-	if err := cfg.PreserveCustom(servicePath); err != nil {
-		log.MustExit(fmt.Errorf("could not preserve existing service configuration: %w", err))
-	} else if len(cfg.Custom) > 0 {
+func prepareConfig(cfg *unit.ServiceConfig, cli *CLI) ([]string, error) {
+	applyOverrides(cfg, cli)
+
+	warnings, err := cfg.Prepare(filepath.Join(confDir, cfg.Name+".service"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Custom) > 0 {
 		log.Printf("Preserved %d custom configuration lines.\n", len(cfg.Custom))
 	}
 
-	cfg.ApplyDefaultAfter()
-	cfg.ApplyDeviceDefaults()
+	return warnings, nil
+}
 
-	if cli.DryRun {
-		dryRun(cfg, confDir)
+func loadTarget(cli *CLI, target Target) (*unit.ServiceConfig, bool) {
+	return loadTargetFrom(cli, target, "")
+}
 
-		return
+// loadTargetFrom loads config ("-" for stdin), or conf/svc.yml when config is
+// empty. Only the implicit conf/svc.yml may be missing.
+func loadTargetFrom(cli *CLI, target Target, config string) (*unit.ServiceConfig, bool) {
+	var (
+		cfg *unit.ServiceConfig
+		err error
+	)
+
+	source := config
+	if source == "" {
+		source = configPath
 	}
 
-	err = writeConfigs(cfg, confDir, configPath, servicePath)
-	log.MustExit(err)
+	if source == "-" {
+		var data []byte
 
-	log.Println("Done. Run 'sudo bash conf/setup.sh' to install.")
+		data, err = io.ReadAll(os.Stdin)
+		if err == nil {
+			cfg, err = unit.ParseConfig(data)
+		}
+	} else {
+		cfg, err = unit.LoadConfig(source)
+	}
+
+	if err != nil && (config != "" || !os.IsNotExist(err)) {
+		log.MustExit(fmt.Errorf("could not load config: %w", err))
+	}
+
+	loaded := cfg != nil
+
+	if loaded {
+		if source == "-" {
+			source = "stdin"
+		}
+
+		log.Printf("Loaded existing configuration from %s\n", source)
+
+		for _, change := range cfg.Migrations {
+			log.Printf("  Migrated: %s\n", change)
+		}
+
+		if target.Name == "" {
+			target.Name = cfg.Name
+		}
+
+		if target.Path == "" {
+			target.Path = cfg.Path
+		}
+	}
+
+	if target.Name == "" || target.Path == "" {
+		log.Println("Usage: mksvc <name> <path> [options]")
+		log.Println("Run 'mksvc -h' for detailed help.")
+
+		os.Exit(1)
+	}
+
+	if cfg == nil {
+		cfg = unit.NewServiceConfig(target.Name, target.Path)
+	} else {
+		cfg.Name = unit.CleanServiceName(target.Name)
+		cfg.Path = target.Path
+
+		cfg.UpdateLabel()
+	}
+
+	if len(cli.AllowedRoots) > 0 {
+		cfg.AllowedRoots = cli.AllowedRoots
+	}
+
+	return cfg, loaded
+}
+
+func initPresetDirs() []string {
+	dirs := []string{"/etc/mksvc/presets.d"}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "mksvc"))
+	}
+
+	return dirs
 }
 
-func applyOverrides(cfg *ServiceConfig, cli *CLI) {
+func applyOverrides(cfg *unit.ServiceConfig, cli *CLI) {
+	if cli.Backend != "" {
+		cfg.Backend = cli.Backend
+	}
+
+	if cli.UserMode {
+		cfg.Backend = "systemd-user"
+	}
+
+	if cli.Image != "" {
+		cfg.Image = cli.Image
+	}
+
 	// Core options
 	if cli.Network != nil {
 		cfg.Network = *cli.Network
@@ -196,6 +383,9 @@ func applyOverrides(cfg *ServiceConfig, cli *CLI) {
 
 	if cli.Devices != nil {
 		cfg.Devices = *cli.Devices
+		if !cfg.Devices {
+			cfg.AllowedDevices = nil
+		}
 	}
 
 	if cli.FullDevices != nil {
@@ -232,10 +422,59 @@ func applyOverrides(cfg *ServiceConfig, cli *CLI) {
 	if cli.EnvFile != "" {
 		cfg.EnvFile = cli.EnvFile
 	}
+
+	// Identity
+	if len(cli.Groups) > 0 {
+		cfg.Groups = cli.Groups
+	}
+
+	// Dependencies
+	if len(cli.DependsOn) > 0 {
+		cfg.DependsOn = cli.DependsOn
+	}
+
+	if len(cli.Wants) > 0 {
+		cfg.Wants = cli.Wants
+	}
+
+	if len(cli.BindsTo) > 0 {
+		cfg.BindsTo = cli.BindsTo
+	}
+
+	if len(cli.Before) > 0 {
+		cfg.Before = cli.Before
+	}
 }
 
-func runInteractive(cfg *ServiceConfig) {
+func runInteractive(cfg *unit.ServiceConfig) error {
 	log.Println("Interactive Configuration")
+
+	// Path section
+	for {
+		err := cfg.ValidatePath()
+		if err == nil {
+			break
+		}
+
+		log.Println()
+		log.Println("Service Path")
+		log.Printf("  Refused: %s\n", err)
+
+		cfg.Path = askString("  Path", cfg.Path)
+	}
+
+	if isTerminal() {
+		return runTUI(cfg)
+	}
+
+	runPrompts(cfg)
+
+	return nil
+}
+
+// runPrompts asks for each option in turn, used when stdin or stdout is not
+// a terminal.
+func runPrompts(cfg *unit.ServiceConfig) {
 	log.Println("Press Enter to accept defaults.")
 
 	// Network section
@@ -398,12 +637,18 @@ func askString(prompt, def string) string {
 	return val
 }
 
-func dryRun(cfg *ServiceConfig, confDir string) {
+func dryRun(cfg *unit.ServiceConfig, artifacts []unit.Artifact, warnings []string) {
 	log.Println("Dry run - no files written.")
 	log.Println()
 	log.Println("Configuration:")
 	log.Printf("  Name:             %s\n", cfg.Name)
 	log.Printf("  Path:             %s\n", cfg.Path)
+	log.Printf("  Backend:          %s\n", valueOr(cfg.Backend, unit.DefaultBackend))
+
+	if cfg.Image != "" {
+		log.Printf("  Image:            %s\n", cfg.Image)
+	}
+
 	log.Println()
 	log.Println("Core Options:")
 	log.Printf("  Network:          %v\n", cfg.Network)
@@ -420,6 +665,11 @@ func dryRun(cfg *ServiceConfig, confDir string) {
 	log.Printf("  RuntimeDir:       %v\n", cfg.RuntimeDir)
 	log.Printf("  Devices:          %v\n", cfg.Devices)
 	log.Printf("  FullDevices:      %v\n", cfg.FullDevices)
+
+	for _, device := range cfg.AllowedDevices {
+		log.Printf("  AllowedDevice:    %s\n", device)
+	}
+
 	log.Printf("  Subprocess:       %v\n", cfg.Subprocess)
 	log.Printf("  SeparateLogDir:   %v\n", cfg.SeparateLogDir)
 	log.Println()
@@ -435,63 +685,137 @@ func dryRun(cfg *ServiceConfig, confDir string) {
 		log.Printf("  EnvFile:          %s\n", cfg.EnvFile)
 	}
 
-	log.Println()
-	log.Println("Would generate:")
-	log.Printf("  %s/%s.service\n", confDir, cfg.Name)
-	log.Printf("  %s/%s.conf\n", confDir, cfg.Name)
-	log.Printf("  %s/%s_logs.conf\n", confDir, cfg.Name)
-	log.Printf("  %s/setup.sh\n", confDir)
-	log.Printf("  %s/uninstall.sh\n", confDir)
-	log.Printf("  %s/svc.yml\n", confDir)
-}
+	if len(cfg.Groups) > 0 {
+		log.Printf("  Groups:           %s\n", strings.Join(cfg.Groups, ", "))
+	}
 
-func valueOr(val, fallback string) string {
-	if val == "" {
-		return fallback
+	if units := cfg.DependencyUnits(); len(units) > 0 {
+		log.Println()
+		log.Println("Dependencies:")
+
+		for _, dependency := range []struct {
+			label string
+			units []string
+		}{
+			{"DependsOn:", cfg.DependsOnUnits()},
+			{"Wants:", cfg.WantsUnits()},
+			{"BindsTo:", cfg.BindsToUnits()},
+			{"Before:", cfg.BeforeUnits()},
+		} {
+			if len(dependency.units) > 0 {
+				log.Printf("  %-17s %s\n", dependency.label, strings.Join(dependency.units, ", "))
+			}
+		}
 	}
 
-	return val
-}
+	if cfg.Restart != nil {
+		restart := cfg.Restart
 
-func writeConfigs(cfg *ServiceConfig, confDir, configPath, servicePath string) error {
-	log.Println("Writing configs...")
+		log.Println()
+		log.Println("Restart Policy:")
+		log.Printf("  Mode:             %s (after %ds)\n", restart.Mode, restart.Delay)
 
-	info, err := os.Lstat(confDir)
-	if os.IsNotExist(err) {
-		err = os.Mkdir(confDir, 0755)
-		if err != nil {
-			return err
+		if restart.Steps > 0 {
+			log.Printf("  Backoff:          %d steps up to %ds\n", restart.Steps, restart.MaxDelay)
+		}
+
+		log.Printf("  StartLimit:       %d starts in %ds\n", restart.Burst, restart.Interval)
+
+		if len(restart.OnFailure) > 0 {
+			log.Printf("  OnFailure:        %s\n", strings.Join(restart.OnFailure, ", "))
 		}
-	} else if err != nil {
-		return err
-	} else if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
-		return fmt.Errorf("%s must be a real directory", confDir)
 	}
 
-	err = cfg.SaveConfig(configPath)
-	if err != nil {
-		return err
+	if cfg.Health != nil || cfg.NotifyOnFailure != nil {
+		log.Println()
+		log.Println("Installation:")
 	}
 
-	err = cfg.WriteTemplate(servicePath, ServiceTmpl)
-	if err != nil {
-		return err
+	if cfg.Health != nil {
+		log.Printf("  HealthCheck:      %s (timeout %ds)\n", cfg.Health, cfg.Health.Timeout)
 	}
 
-	err = cfg.WriteTemplate(filepath.Join(confDir, "{name}.conf"), UserTmpl)
-	if err != nil {
-		return err
+	if cfg.NotifyOnFailure != nil {
+		log.Printf("  NotifyOnFailure:  %s (%d journal lines)\n", cfg.NotifyOnFailure, cfg.NotifyOnFailure.Lines)
 	}
 
-	err = cfg.WriteTemplate(filepath.Join(confDir, "setup.sh"), SetupTmpl)
-	if err != nil {
-		return err
+	if len(warnings) > 0 {
+		log.Println()
+		log.Println("Warnings:")
+
+		for _, warning := range warnings {
+			log.Printf("  %s\n", warning)
+		}
+	}
+
+	log.Println()
+	log.Println("Would generate:")
+
+	for _, artifact := range artifacts {
+		log.Printf("  %s\n", artifact.Path)
+	}
+}
+
+func dryRunReport(cfg *unit.ServiceConfig, artifacts []unit.Artifact, warnings []string, format string) error {
+	report := struct {
+		Config    *unit.ServiceConfig `json:"config" yaml:"config"`
+		Artifacts []reportArtifact    `json:"artifacts" yaml:"artifacts"`
+		Warnings  []string            `json:"warnings" yaml:"warnings"`
+	}{
+		Config:    cfg,
+		Artifacts: make([]reportArtifact, 0, len(artifacts)),
+		Warnings:  append([]string{}, warnings...),
+	}
+
+	for _, artifact := range artifacts {
+		report.Artifacts = append(report.Artifacts, reportArtifact{
+			Path:    artifact.Path,
+			Mode:    fmt.Sprintf("%04o", artifact.Mode.Perm()),
+			Content: string(artifact.Data),
+		})
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(report, "", "  ")
+
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.MarshalWithOptions(report, yaml.UseLiteralStyleIfMultiline(true))
+	default:
+		return fmt.Errorf("unsupported format %q", format)
 	}
 
-	err = cfg.WriteTemplate(filepath.Join(confDir, "uninstall.sh"), UninstallTmpl)
 	if err != nil {
 		return err
 	}
 
-	return cfg.WriteTemplate(filepath.Join(confDir, "{name}_logs.conf"), LogrotateTmpl)
+	_, err = os.Stdout.Write(data)
+
+	return err
+}
+
+type reportArtifact struct {
+	Path    string `json:"path" yaml:"path"`
+	Mode    string `json:"mode" yaml:"mode"`
+	Content string `json:"content" yaml:"content"`
+}
+
+func valueOr(val, fallback string) string {
+	if val == "" {
+		return fallback
+	}
+
+	return val
+}
+
+func writeConfigs(confDir string, artifacts []unit.Artifact) error {
+	log.Println("Writing configs...")
+
+	return unit.WriteArtifacts(confDir, artifacts)
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"mksvc/unit"
+)
+
+type MigrateCmd struct {
+	Config string `short:"c" name:"config" placeholder:"FILE" help:"Configuration file to migrate (default: conf/svc.yml)."`
+}
+
+func (cmd *MigrateCmd) Run(cli *CLI) error {
+	source := configPath
+
+	if cmd.Config != "" {
+		source = cmd.Config
+	}
+
+	cfg, err := unit.LoadConfig(source)
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	if len(cfg.Migrations) == 0 {
+		log.Printf("%s is already at version %d.\n", source, unit.ConfigVersion)
+
+		return nil
+	}
+
+	log.Printf("Migrating %s:\n", source)
+
+	for _, change := range cfg.Migrations {
+		log.Printf("  %s\n", change)
+	}
+
+	if cli.DryRun {
+		log.Println("Dry run - no files written.")
+
+		return nil
+	}
+
+	err = cfg.SaveConfig(source)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Done.")
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"mksvc/unit"
+)
+
+// NotifyCmd field names differ from FailureNotify so their help does not end
+// up in the schema.
+type NotifyCmd struct {
+	Unit         string `arg:"" help:"Failed unit to report (e.g. myapp.service)."`
+	WebhookURL   string `name:"webhook" env:"MKSVC_NOTIFY_WEBHOOK" help:"URL to POST the notification to as JSON."`
+	ShellCommand string `name:"command" env:"MKSVC_NOTIFY_COMMAND" help:"Shell command to run, journal lines are passed on stdin."`
+	JournalLines int    `name:"lines" env:"MKSVC_NOTIFY_LINES" default:"20" help:"Number of journal lines to include."`
+}
+
+func (cmd *NotifyCmd) Run(cli *CLI) error {
+	settings := unit.FailureNotify{
+		Webhook: cmd.WebhookURL,
+		Command: cmd.ShellCommand,
+		Lines:   cmd.JournalLines,
+	}
+
+	err := settings.Validate()
+	if err != nil {
+		return err
+	}
+
+	lines, err := journalLines(cmd.Unit, cmd.JournalLines)
+	if err != nil {
+		log.Warnf("Could not read the journal of %s: %v\n", cmd.Unit, err)
+	}
+
+	notification := unit.NewNotification(cmd.Unit, lines)
+
+	if cmd.WebhookURL != "" {
+		log.Printf("Notifying %s about %s...\n", cmd.WebhookURL, cmd.Unit)
+
+		return notification.Post(cmd.WebhookURL, &http.Client{
+			Timeout: 10 * time.Second,
+		})
+	}
+
+	log.Printf("Running notification command for %s...\n", cmd.Unit)
+
+	return notification.Run(cmd.ShellCommand, os.Stdout, os.Stderr)
+}
+
+func journalLines(name string, count int) ([]string, error) {
+	out, err := exec.Command("journalctl", "-u", name, "-n", strconv.Itoa(count), "--no-pager", "--quiet", "-o", "short-iso").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return nil, nil
+	}
+
+	return strings.Split(text, "\n"), nil
+}
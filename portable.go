@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"mksvc/unit"
+)
+
+var (
+	// Mount points portablectl expects in every image
+	portableDirs = []string{"proc", "sys", "dev", "run", "tmp", "var/tmp", "etc", unit.PortableUnitPath}
+
+	// Bind mounted from the host by the portable profiles when present
+	portableEmptyFiles = []string{"etc/resolv.conf", "etc/machine-id"}
+)
+
+type PortableCmd struct {
+	Target
+
+	Config   string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml."`
+	Output   string `short:"o" name:"output" placeholder:"PATH" help:"Image directory, or image file with --squashfs (default: conf/portable/<name>)."`
+	Squashfs bool   `name:"squashfs" help:"Pack the image into a squashfs file with mksquashfs."`
+}
+
+func (cmd *PortableCmd) Run(cli *CLI) error {
+	cfg, _ := loadTargetFrom(cli, cmd.Target, cmd.Config)
+
+	_, err := prepareConfig(cfg, cli)
+	if err != nil {
+		return err
+	}
+
+	unitData, err := cfg.PortableUnit()
+	if err != nil {
+		return err
+	}
+
+	executable := filepath.Join(cfg.Path, cfg.Name)
+
+	libraries, err := unit.CollectLibraries(executable)
+	if err != nil {
+		return err
+	}
+
+	output := cmd.Output
+
+	if output == "" {
+		output = filepath.Join(confDir, "portable", cfg.Name)
+
+		if cmd.Squashfs {
+			output += ".raw"
+		}
+	}
+
+	// portablectl only attaches units whose names start with the image name
+	image := strings.TrimSuffix(filepath.Base(output), ".raw")
+
+	if image != cfg.Name && !strings.HasPrefix(image, cfg.Name+"_") {
+		return fmt.Errorf("image name %s must be %s or start with %s_", image, cfg.Name, cfg.Name)
+	}
+
+	if cli.DryRun {
+		log.Printf("Would build portable image %s containing:\n", output)
+		log.Printf("  %s\n", executable)
+
+		for _, library := range libraries {
+			log.Printf("  %s\n", library)
+		}
+
+		log.Printf("  /%s/%s.service\n", unit.PortableUnitPath, cfg.Name)
+		log.Println("Dry run - no files written.")
+
+		return nil
+	}
+
+	if cmd.Squashfs {
+		if _, err := exec.LookPath("mksquashfs"); err != nil {
+			return fmt.Errorf("--squashfs needs mksquashfs (squashfs-tools)")
+		}
+	}
+
+	err = checkPortableOutput(output, cmd.Squashfs)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(output), "."+image+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(staging)
+
+	err = buildPortableImage(staging, cfg, unitData, append([]string{executable}, libraries...))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Squashfs {
+		err = packSquashfs(staging, output)
+	} else {
+		err = replaceDir(staging, output)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !cmd.Squashfs && os.Geteuid() != 0 {
+		log.Warnln("The image is owned by you; build it as root or use --squashfs before attaching it.")
+	}
+
+	absolute, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Built portable image %s (%d libraries)\n", output, len(libraries))
+	log.Println("Run conf/setup.sh first, then replace the unit it installed with the image:")
+	log.Printf("  sudo systemctl disable --now %s\n", cfg.Name)
+	log.Printf("  sudo rm %s\n", cfg.InstalledFiles()["unit"])
+	log.Printf("  sudo portablectl attach --enable --now %s\n", absolute)
+
+	return nil
+}
+
+// checkPortableOutput refuses to replace anything that is not an image built
+// by an earlier run.
+func checkPortableOutput(output string, squashfs bool) error {
+	info, err := os.Lstat(output)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if squashfs {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("refusing to replace %s, it is not a regular file", output)
+		}
+
+		return nil
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("refusing to replace %s, it is not a directory", output)
+	}
+
+	if _, err := os.Lstat(filepath.Join(output, "usr/lib/os-release")); err != nil {
+		return fmt.Errorf("refusing to replace %s, it does not look like a portable image", output)
+	}
+
+	return nil
+}
+
+func buildPortableImage(root string, cfg *unit.ServiceConfig, unitData []byte, files []string) error {
+	err := os.Chmod(root, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range portableDirs {
+		err = os.MkdirAll(filepath.Join(root, dir), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range portableEmptyFiles {
+		err = os.WriteFile(filepath.Join(root, file), nil, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	release, err := os.ReadFile("/etc/os-release")
+	if os.IsNotExist(err) {
+		release, err = os.ReadFile("/usr/lib/os-release")
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not read os-release: %w", err)
+	}
+
+	if len(release) > 0 && !bytes.HasSuffix(release, []byte("\n")) {
+		release = append(release, '\n')
+	}
+
+	// The libraries come from the host, so the image describes the host
+	release = append(release, []byte("PORTABLE_PREFIXES="+cfg.Name+"\n")...)
+
+	err = os.WriteFile(filepath.Join(root, "usr/lib/os-release"), release, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(root, unit.PortableUnitPath, cfg.Name+".service"), unitData, 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err = copyIntoImage(root, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	// BindPaths needs the mount points to exist in the read-only image
+	for _, path := range cfg.PortableWritablePaths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("%s is missing, run conf/setup.sh first", path)
+		}
+
+		target := filepath.Join(root, path)
+
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			err = os.Mkdir(target, 0755)
+		} else {
+			err = os.WriteFile(target, nil, 0644)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyIntoImage(root, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, file)
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(target, data, info.Mode().Perm()&0755)
+}
+
+func packSquashfs(dir, output string) error {
+	temp := output + ".tmp"
+
+	out, err := exec.Command("mksquashfs", dir, temp, "-noappend", "-all-root", "-quiet").CombinedOutput()
+	if err != nil {
+		os.Remove(temp)
+
+		return fmt.Errorf("mksquashfs failed: %w\n%s", err, out)
+	}
+
+	return os.Rename(temp, output)
+}
+
+func replaceDir(dir, output string) error {
+	err := os.RemoveAll(output)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(dir, output)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mksvc/unit"
+)
+
+var backupRgx = regexp.MustCompile(`^\d{8}T\d{6}Z-[A-Za-z0-9]{6}$`)
+
+type RestoreCmd struct {
+	Config    string `short:"c" name:"config" placeholder:"FILE" help:"Read configuration from FILE instead of conf/svc.yml."`
+	From      string `name:"from" placeholder:"BACKUP" help:"Backup to restore (default: the newest)."`
+	List      bool   `name:"list" help:"List available backups."`
+	NoRestart bool   `name:"no-restart" help:"Restore files without restarting the service."`
+}
+
+func (cmd *RestoreCmd) Run(cli *CLI) error {
+	cfg, err := loadServiceConfig(cli, cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	if cfg.UserMode() {
+		return fmt.Errorf("user services keep no backups; rerun conf/setup.sh with an older svc.yml instead")
+	}
+
+	root := filepath.Join(unit.BackupRoot, cfg.Name)
+
+	backups, err := listBackups(root)
+	if err != nil {
+		return err
+	}
+
+	if cmd.List {
+		log.Printf("Backups in %s (newest last):\n", root)
+
+		for _, backup := range backups {
+			log.Printf("  %s\n", backup)
+		}
+
+		return nil
+	}
+
+	backup := backups[len(backups)-1]
+
+	if cmd.From != "" {
+		if !slices.Contains(backups, cmd.From) {
+			return fmt.Errorf("unknown backup %s", cmd.From)
+		}
+
+		backup = cmd.From
+	}
+
+	dir := filepath.Join(root, backup)
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest"))
+	if err != nil {
+		return err
+	}
+
+	manifest, err := cfg.ParseBackupManifest(data)
+	if err != nil {
+		return fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+
+	log.Printf("Restoring backup %s\n", backup)
+
+	if cli.DryRun {
+		for _, file := range manifest.Files {
+			if file.Stored == "" {
+				if _, err := os.Lstat(file.Path); err == nil {
+					log.Printf("  Would remove %s\n", file.Path)
+				}
+			} else {
+				log.Printf("  Would restore %s\n", file.Path)
+			}
+		}
+
+		for _, stat := range manifest.Stats {
+			log.Printf("  Would set %s to %d:%d %04o\n", stat.Path, stat.UID, stat.GID, stat.Mode)
+		}
+
+		log.Println("Dry run - no files written.")
+
+		return nil
+	}
+
+	installed := make(map[string]bool)
+
+	for _, file := range manifest.Files {
+		err = restoreFile(dir, file)
+		if err != nil {
+			return err
+		}
+
+		installed[file.Path] = file.Stored != ""
+	}
+
+	for _, stat := range manifest.Stats {
+		err = restoreStat(cfg.Path, stat)
+		if err != nil {
+			return err
+		}
+	}
+
+	files := cfg.InstalledFiles()
+
+	err = serviceAction(cfg, unit.ActionReload)
+	if err != nil {
+		return err
+	}
+
+	if sysusers, ok := files["sysusers"]; ok && installed[sysusers] {
+		out, err := exec.Command("systemd-sysusers", sysusers).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("systemd-sysusers failed: %w\n%s", err, out)
+		}
+	}
+
+	if udev, ok := files["udev"]; ok && slices.ContainsFunc(manifest.Files, func(file unit.BackupFile) bool {
+		return file.Path == udev
+	}) {
+		out, err := exec.Command("udevadm", "control", "--reload-rules").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("udevadm failed: %w\n%s", err, out)
+		}
+	}
+
+	if !installed[files["unit"]] {
+		log.Println("No unit was installed before this backup; disabling the service.")
+
+		_ = serviceAction(cfg, unit.ActionDisable)
+	} else if !cmd.NoRestart {
+		log.Printf("Restarting %s...\n", cfg.Name)
+
+		err = serviceAction(cfg, unit.ActionRestart)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Println("Done.")
+
+	return nil
+}
+
+// listBackups returns the backup names in root, oldest first.
+func listBackups(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no backups in %s, run conf/setup.sh first", root)
+		}
+
+		return nil, err
+	}
+
+	var backups []string
+
+	for _, entry := range entries {
+		if entry.Type() == os.ModeDir && backupRgx.MatchString(entry.Name()) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups in %s, run conf/setup.sh first", root)
+	}
+
+	return backups, nil
+}
+
+func restoreFile(dir string, file unit.BackupFile) error {
+	info, err := os.Lstat(file.Path)
+	if err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("refusing to replace non-regular file %s", file.Path)
+	}
+
+	if file.Stored == "" {
+		if err == nil {
+			log.Printf("  Removing %s\n", file.Path)
+
+			return os.Remove(file.Path)
+		}
+
+		return nil
+	}
+
+	stored := filepath.Join(dir, file.Stored)
+
+	data, err := os.ReadFile(stored)
+	if err != nil {
+		return err
+	}
+
+	info, err = os.Stat(stored)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("  Restoring %s\n", file.Path)
+
+	// Init scripts are executable, everything else is plain configuration
+	return unit.WriteFileAtomic(file.Path, data, info.Mode().Perm()&0755)
+}
+
+func restoreStat(root string, stat unit.BackupStat) error {
+	// Never follow a symlink planted below the service path
+	rel, err := filepath.Rel(root, stat.Path)
+	if err != nil {
+		return err
+	}
+
+	current := root
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			log.Printf("  Skipping %s: %s is a symlink\n", stat.Path, current)
+
+			return nil
+		}
+	}
+
+	err = os.Lchown(stat.Path, stat.UID, stat.GID)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(stat.Path, stat.Mode)
+}
+
+// serviceAction runs the commands of the configured backend for action.
+func serviceAction(cfg *unit.ServiceConfig, action string) error {
+	backend, err := unit.LookupBackend(cfg.Backend)
+	if err != nil {
+		return err
+	}
+
+	for _, args := range backend.Commands(cfg.Name, action) {
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s failed: %w\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+
+	"mksvc/unit"
+)
+
+func schemaURL() string {
+	if Version == "dev" {
+		return "https://github.com/coalaura/mksvc/releases/latest/download/svc.schema.json"
+	}
+
+	return "https://github.com/coalaura/mksvc/releases/download/" + Version + "/svc.schema.json"
+}
+
+// Descriptions come from the CLI help so both stay in sync.
+func cliDescriptions() map[string]string {
+	descriptions := make(map[string]string)
+
+	var walk func(typ reflect.Type)
+
+	walk = func(typ reflect.Type) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+
+				continue
+			}
+
+			if help := field.Tag.Get("help"); help != "" {
+				if _, exists := descriptions[field.Name]; !exists {
+					descriptions[field.Name] = strings.ReplaceAll(help, "%%", "%")
+				}
+			}
+		}
+	}
+
+	walk(reflect.TypeFor[CLI]())
+
+	return descriptions
+}
+
+type SchemaCmd struct{}
+
+func (cmd *SchemaCmd) Run() error {
+	data, err := json.MarshalIndent(unit.Schema(cliDescriptions()), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	_, err = os.Stdout.Write(data)
+
+	return err
+}
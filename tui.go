@@ -0,0 +1,757 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode"
+
+	"golang.org/x/term"
+
+	"mksvc/unit"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiReverse = "\x1b[7m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+
+	tuiMenuWidth  = 42
+	tuiFooterRows = 4
+)
+
+var errInteractiveAborted = errors.New("interactive configuration aborted")
+
+// tuiItem is one option of the interactive editor, either a toggle (flag)
+// or a free text value (text).
+type tuiItem struct {
+	title string
+	desc  string
+
+	flag func(cfg *unit.ServiceConfig) *bool
+	text func(cfg *unit.ServiceConfig) *string
+
+	// locked returns why the option cannot be changed right now
+	locked func(cfg *unit.ServiceConfig) string
+}
+
+type tuiSection struct {
+	title string
+	items []tuiItem
+}
+
+var tuiSections = []tuiSection{
+	{
+		title: "Network",
+		items: []tuiItem{
+			{
+				title: "Network Access",
+				desc:  "Required for internet/intranet. Disabling creates an airgapped namespace.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.Network },
+			},
+			{
+				title:  "Server Mode",
+				desc:   "Allows port binding. Waits for network-online.target before starting.",
+				flag:   func(cfg *unit.ServiceConfig) *bool { return &cfg.Listening },
+				locked: requires("Network Access", func(cfg *unit.ServiceConfig) bool { return cfg.Network }),
+			},
+			{
+				title:  "Privileged Ports",
+				desc:   "Allow binding to ports <1024 (80/443) via CAP_NET_BIND_SERVICE.",
+				flag:   func(cfg *unit.ServiceConfig) *bool { return &cfg.PrivilegedPorts },
+				locked: requires("Server Mode", func(cfg *unit.ServiceConfig) bool { return cfg.Listening }),
+			},
+			{
+				title:  "Localhost Only",
+				desc:   "Restrict network to 127.0.0.0/8 and ::1. For local database access.",
+				flag:   func(cfg *unit.ServiceConfig) *bool { return &cfg.LocalhostOnly },
+				locked: requires("Network Access", func(cfg *unit.ServiceConfig) bool { return cfg.Network }),
+			},
+		},
+	},
+	{
+		title: "Filesystem",
+		items: []tuiItem{
+			{
+				title: "Writable Directory",
+				desc:  "Creates a writable data directory inside the service root.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.WritableFiles },
+			},
+			{
+				title: "Writable Config File",
+				desc:  "Allows one application config file next to the executable to update itself.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.WritableConfig },
+			},
+			{
+				title:  "Config Filename",
+				desc:   "Name of the writable config file, relative to the service root.",
+				text:   func(cfg *unit.ServiceConfig) *string { return &cfg.ConfigFile },
+				locked: requires("Writable Config File", func(cfg *unit.ServiceConfig) bool { return cfg.WritableConfig }),
+			},
+			{
+				title: "Runtime Directory",
+				desc:  "Creates /run/<name> for sockets or PID files.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.RuntimeDir },
+			},
+			{
+				title: "Separate Logs",
+				desc:  "Organize logs into a 'logs' subdirectory.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.SeparateLogDir },
+			},
+		},
+	},
+	{
+		title: "Hardware",
+		items: []tuiItem{
+			{
+				title: "Hardware Devices",
+				desc:  "Access to /dev (USB, serial, GPU).",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.Devices },
+			},
+			{
+				title:  "Full Device Access",
+				desc:   "Disables device sandboxing. Use if standard rules fail.",
+				flag:   func(cfg *unit.ServiceConfig) *bool { return &cfg.FullDevices },
+				locked: requires("Hardware Devices", func(cfg *unit.ServiceConfig) bool { return cfg.Devices }),
+			},
+		},
+	},
+	{
+		title: "Process",
+		items: []tuiItem{
+			{
+				title: "Executable Memory",
+				desc:  "Required for JIT runtimes (Node, Java) or Go WASM (wazero).",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.ExecMemory },
+			},
+			{
+				title: "Subprocesses",
+				desc:  "Allow spawning shell commands or external binaries.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.Subprocess },
+			},
+			{
+				title: "Private Users",
+				desc:  "User namespace isolation. May break user lookups or capabilities.",
+				flag:  func(cfg *unit.ServiceConfig) *bool { return &cfg.PrivateUsers },
+				locked: func(cfg *unit.ServiceConfig) string {
+					if cfg.UserMode() {
+						return "always enabled for user services"
+					}
+
+					_, reason := cfg.CanHavePrivateUsers()
+
+					return reason
+				},
+			},
+		},
+	},
+	{
+		title: "Resources",
+		items: []tuiItem{
+			{
+				title: "CPU Quota",
+				desc:  "CPUQuota, e.g. 200% for two cores. Leave empty for no limit.",
+				text:  func(cfg *unit.ServiceConfig) *string { return &cfg.CPUQuota },
+			},
+			{
+				title: "Memory Max",
+				desc:  "MemoryMax, e.g. 512M or 2G. Leave empty for no limit.",
+				text:  func(cfg *unit.ServiceConfig) *string { return &cfg.MemoryMax },
+			},
+		},
+	},
+}
+
+func requires(title string, enabled func(cfg *unit.ServiceConfig) bool) func(cfg *unit.ServiceConfig) string {
+	return func(cfg *unit.ServiceConfig) string {
+		if enabled(cfg) {
+			return ""
+		}
+
+		return "requires " + title
+	}
+}
+
+type tui struct {
+	cfg         *unit.ServiceConfig
+	servicePath string
+	previewFile string
+
+	// cursor indexes the flattened items of all sections
+	cursor int
+	items  []tuiItem
+	titles []string
+
+	editing bool
+	input   string
+
+	preview []string
+	changed []bool
+	added   int
+	removed int
+	scroll  int
+	height  int
+
+	score    float64
+	rating   string
+	exposed  []string
+	warnings []string
+	err      error
+	message  string
+}
+
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// runTUI edits cfg in a full-screen editor until the user is done (d) or
+// aborts (q).
+func runTUI(cfg *unit.ServiceConfig) error {
+	backend, err := unit.LookupBackend(cfg.Backend)
+	if err != nil {
+		return err
+	}
+
+	ui := &tui{
+		cfg:         cfg,
+		servicePath: filepath.Join(confDir, cfg.Name+".service"),
+		previewFile: strings.Replace(backend.Templates()[0].File, "{name}", cfg.Name, 1),
+	}
+
+	for _, section := range tuiSections {
+		for _, item := range section.items {
+			ui.items = append(ui.items, item)
+			ui.titles = append(ui.titles, section.title)
+		}
+	}
+
+	ui.update()
+
+	fd := int(os.Stdin.Fd())
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+
+	defer term.Restore(fd, state)
+
+	// Alternate screen, hidden cursor
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	var mu sync.Mutex
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+
+	defer func() {
+		signal.Stop(resize)
+		close(resize)
+	}()
+
+	go func() {
+		for range resize {
+			mu.Lock()
+			ui.draw()
+			mu.Unlock()
+		}
+	}()
+
+	buf := make([]byte, 64)
+
+	for {
+		mu.Lock()
+		ui.draw()
+		mu.Unlock()
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		done, err := ui.handle(string(buf[:n]))
+		mu.Unlock()
+
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+func (ui *tui) current() tuiItem {
+	return ui.items[ui.cursor]
+}
+
+func (ui *tui) handle(key string) (bool, error) {
+	if ui.editing {
+		ui.edit(key)
+
+		return false, nil
+	}
+
+	ui.message = ""
+
+	// A lone ESC does not abort, a slow terminal may deliver the rest of an
+	// escape sequence in the next read
+	switch key {
+	case "\x03", "q":
+		return true, errInteractiveAborted
+	case "\x1b[A", "k":
+		ui.cursor = (ui.cursor + len(ui.items) - 1) % len(ui.items)
+	case "\x1b[B", "j":
+		ui.cursor = (ui.cursor + 1) % len(ui.items)
+	case "\x1b[D", "h", "\x1b[Z":
+		ui.jump(-1)
+	case "\x1b[C", "l", "\t":
+		ui.jump(1)
+	case "\x1b[5~":
+		ui.scrollBy(-ui.height)
+	case "\x1b[6~":
+		ui.scrollBy(ui.height)
+	case " ", "\r":
+		ui.activate()
+	case "d":
+		if ui.err != nil {
+			ui.message = "Fix the error before finishing."
+
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// jump moves the cursor to the first item of the previous or next section.
+func (ui *tui) jump(direction int) {
+	section := ui.titles[ui.cursor]
+
+	index := ui.cursor
+
+	if direction < 0 {
+		// Back to the start of the current section first
+		for index > 0 && ui.titles[index-1] == section {
+			index--
+		}
+
+		if index == ui.cursor {
+			index = (index + len(ui.items) - 1) % len(ui.items)
+
+			for index > 0 && ui.titles[index-1] == ui.titles[index] {
+				index--
+			}
+		}
+
+		ui.cursor = index
+
+		return
+	}
+
+	for index < len(ui.items) && ui.titles[index] == section {
+		index++
+	}
+
+	ui.cursor = index % len(ui.items)
+}
+
+func (ui *tui) activate() {
+	item := ui.current()
+
+	if item.locked != nil {
+		if reason := item.locked(ui.cfg); reason != "" {
+			ui.message = item.title + " " + reason + "."
+
+			return
+		}
+	}
+
+	if item.flag != nil {
+		value := item.flag(ui.cfg)
+
+		*value = !*value
+
+		ui.update()
+
+		return
+	}
+
+	ui.editing = true
+	ui.input = *item.text(ui.cfg)
+}
+
+func (ui *tui) edit(key string) {
+	switch key {
+	case "\r":
+		*ui.current().text(ui.cfg) = strings.TrimSpace(ui.input)
+
+		ui.editing = false
+
+		ui.update()
+	case "\x1b", "\x03":
+		ui.editing = false
+	case "\x7f", "\b":
+		runes := []rune(ui.input)
+
+		if len(runes) > 0 {
+			ui.input = string(runes[:len(runes)-1])
+		}
+	default:
+		for _, r := range key {
+			if !unicode.IsPrint(r) {
+				return
+			}
+		}
+
+		ui.input += key
+	}
+}
+
+// update applies the coupling between options and re-renders the preview
+// from a copy of the configuration.
+func (ui *tui) update() {
+	cfg := ui.cfg
+
+	cfg.Normalize()
+
+	if !cfg.WritableConfig {
+		cfg.ConfigFile = ""
+	} else if cfg.ConfigFile == "" {
+		cfg.ConfigFile = "config.yml"
+	}
+
+	preview := cfg.Clone()
+
+	warnings, err := preview.Prepare(ui.servicePath)
+
+	ui.err = err
+
+	if err != nil {
+		return
+	}
+
+	ui.warnings = warnings
+
+	ui.score = preview.ExposureScore()
+	ui.rating = unit.ExposureRating(ui.score)
+	ui.exposed = ui.exposed[:0]
+
+	for _, exposure := range preview.Exposures() {
+		ui.exposed = append(ui.exposed, exposure.Setting)
+	}
+
+	backend, _ := unit.LookupBackend(preview.Backend)
+
+	data, err := preview.RenderArtifact(backend.Templates()[0].Kind)
+	if err != nil {
+		ui.err = err
+
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "\t", "    ")
+	}
+
+	if ui.preview == nil {
+		ui.preview = lines
+		ui.changed = make([]bool, len(lines))
+
+		return
+	}
+
+	ui.changed, ui.added, ui.removed = changedLines(ui.preview, lines)
+	ui.preview = lines
+
+	// Bring the first change into view
+	for i, changed := range ui.changed {
+		if changed {
+			if i < ui.scroll || i >= ui.scroll+ui.height {
+				ui.scroll = i - ui.height/3
+			}
+
+			break
+		}
+	}
+
+	ui.scrollBy(0)
+}
+
+// changedLines marks the lines of next that are not in prev and counts
+// added and removed lines. Units have few repeated lines, so comparing line
+// multisets is close enough to a real diff.
+func changedLines(prev, next []string) ([]bool, int, int) {
+	counts := make(map[string]int)
+
+	for _, line := range prev {
+		counts[line]++
+	}
+
+	var (
+		changed = make([]bool, len(next))
+		added   int
+		removed int
+	)
+
+	for i, line := range next {
+		if counts[line] > 0 {
+			counts[line]--
+
+			continue
+		}
+
+		changed[i] = true
+		added++
+	}
+
+	for _, count := range counts {
+		removed += count
+	}
+
+	return changed, added, removed
+}
+
+func (ui *tui) scrollBy(delta int) {
+	ui.scroll += delta
+
+	ui.scroll = min(ui.scroll, len(ui.preview)-ui.height)
+	ui.scroll = max(ui.scroll, 0)
+}
+
+func (ui *tui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+
+	// Never write the last column, some terminals wrap early
+	width--
+
+	var screen strings.Builder
+
+	screen.WriteString("\x1b[H\x1b[2J")
+
+	if width < tuiMenuWidth+20 || height < tuiFooterRows+8 {
+		screen.WriteString("Terminal too small, resize or press q to abort.")
+
+		os.Stdout.WriteString(screen.String())
+
+		return
+	}
+
+	body := height - tuiFooterRows - 2
+	previewWidth := width - tuiMenuWidth - 3
+
+	ui.height = body
+	ui.scrollBy(0)
+
+	rows := make([]string, 0, height)
+
+	// Header
+	diff := ""
+
+	if ui.added > 0 || ui.removed > 0 {
+		diff = fmt.Sprintf("  +%d -%d", ui.added, ui.removed)
+	}
+
+	rows = append(rows,
+		ansiBold+fit(" mksvc · "+ui.cfg.Name+" · "+ui.cfg.Path, tuiMenuWidth)+ansiReset+" │ "+
+			ansiBold+fit(ui.previewFile, previewWidth-len(diff))+ansiReset+ansiGreen+diff+ansiReset,
+		strings.Repeat("─", tuiMenuWidth)+"─┼─"+strings.Repeat("─", previewWidth),
+	)
+
+	menu := ui.menu()
+
+	// Keep the selected item visible in the menu
+	offset := 0
+
+	for i, line := range menu {
+		if line.selected && i >= body {
+			offset = i - body + 1
+		}
+	}
+
+	for row := range body {
+		var left, right string
+
+		if i := offset + row; i < len(menu) {
+			left = menu[i].render(tuiMenuWidth)
+		} else {
+			left = fit("", tuiMenuWidth)
+		}
+
+		if i := ui.scroll + row; i < len(ui.preview) {
+			if ui.changed[i] {
+				right = ansiGreen + fit("+ "+ui.preview[i], previewWidth) + ansiReset
+			} else {
+				right = fit("  "+ui.preview[i], previewWidth)
+			}
+		}
+
+		rows = append(rows, left+" │ "+right)
+	}
+
+	rows = append(rows, strings.Repeat("─", tuiMenuWidth)+"─┴─"+strings.Repeat("─", previewWidth))
+	rows = append(rows, ui.footer(width)...)
+
+	screen.WriteString(strings.Join(rows, "\r\n"))
+
+	os.Stdout.WriteString(screen.String())
+}
+
+type tuiLine struct {
+	text     string
+	style    string
+	selected bool
+}
+
+func (l tuiLine) render(width int) string {
+	style := l.style
+
+	if l.selected {
+		style += ansiReverse
+	}
+
+	if style == "" {
+		return fit(l.text, width)
+	}
+
+	return style + fit(l.text, width) + ansiReset
+}
+
+func (ui *tui) menu() []tuiLine {
+	var lines []tuiLine
+
+	for i, item := range ui.items {
+		if i == 0 || ui.titles[i] != ui.titles[i-1] {
+			lines = append(lines, tuiLine{text: ui.titles[i], style: ansiBold})
+		}
+
+		var (
+			reason string
+			line   tuiLine
+		)
+
+		if item.locked != nil {
+			reason = item.locked(ui.cfg)
+		}
+
+		if item.flag != nil {
+			mark := "[ ]"
+
+			if *item.flag(ui.cfg) {
+				mark = "[x]"
+			}
+
+			// Prepare turns private users off, show what will be generated
+			if reason != "" && item.title == "Private Users" && !ui.cfg.UserMode() {
+				mark = "[ ]"
+			}
+
+			line.text = "  " + mark + " " + item.title
+		} else {
+			value := *item.text(ui.cfg)
+
+			if ui.editing && i == ui.cursor {
+				value = ui.input + "_"
+			} else if value == "" {
+				value = "none"
+			}
+
+			line.text = "  " + item.title + ": " + value
+		}
+
+		if reason != "" {
+			line.style = ansiDim
+		}
+
+		line.selected = i == ui.cursor
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func (ui *tui) footer(width int) []string {
+	rows := make([]string, 0, tuiFooterRows)
+
+	// Exposure score
+	color := ansiGreen
+
+	switch ui.rating {
+	case "MEDIUM":
+		color = ansiYellow
+	case "HIGH":
+		color = ansiRed
+	}
+
+	filled := int(math.Round(ui.score * 2))
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", 20-filled)
+	score := fmt.Sprintf(" Exposure %4.1f/10 %-6s ", ui.score, ui.rating)
+
+	rows = append(rows, color+ansiBold+score+ansiReset+color+bar+ansiReset+ansiDim+fit("  "+strings.Join(ui.exposed, ", "), width-len(score)-20)+ansiReset)
+
+	// Description of the selected item
+	item := ui.current()
+	desc := " " + item.desc
+
+	if item.locked != nil {
+		if reason := item.locked(ui.cfg); reason != "" {
+			desc += " (" + reason + ")"
+		}
+	}
+
+	rows = append(rows, fit(desc, width))
+
+	// Status
+	switch {
+	case ui.message != "":
+		rows = append(rows, ansiYellow+fit(" "+ui.message, width)+ansiReset)
+	case ui.err != nil:
+		rows = append(rows, ansiRed+fit(" Error: "+ui.err.Error(), width)+ansiReset)
+	case len(ui.warnings) > 0:
+		rows = append(rows, ansiYellow+fit(" Warning: "+strings.Join(ui.warnings, "; "), width)+ansiReset)
+	default:
+		rows = append(rows, fit("", width))
+	}
+
+	help := " ↑↓ move  ←→ section  space toggle  enter edit  PgUp/PgDn scroll  d done  q abort"
+
+	if ui.editing {
+		help = " enter confirm  esc cancel  backspace delete"
+	}
+
+	rows = append(rows, ansiDim+fit(help, width)+ansiReset)
+
+	return rows
+}
+
+// fit truncates or pads s to exactly width runes.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(s)
+
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+
+	return s + strings.Repeat(" ", width-len(runes))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestChangedLines(t *testing.T) {
+	cases := []struct {
+		name    string
+		prev    []string
+		next    []string
+		changed []bool
+		added   int
+		removed int
+	}{
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, []bool{false, false}, 0, 0},
+		{"replaced", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []bool{false, true, false}, 1, 1},
+		{"added", []string{"a"}, []string{"a", "b"}, []bool{false, true}, 1, 0},
+		{"removed", []string{"a", "b"}, []string{"a"}, []bool{false}, 0, 1},
+		{"repeated", []string{"a", "a"}, []string{"a", "a", "a"}, []bool{false, false, true}, 1, 0},
+		{"reordered", []string{"a", "b"}, []string{"b", "a"}, []bool{false, false}, 0, 0},
+		{"empty", nil, []string{"a"}, []bool{true}, 1, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed, added, removed := changedLines(tc.prev, tc.next)
+
+			if !slices.Equal(changed, tc.changed) || added != tc.added || removed != tc.removed {
+				t.Errorf("got %v +%d -%d, want %v +%d -%d", changed, added, removed, tc.changed, tc.added, tc.removed)
+			}
+		})
+	}
+}
+
+// navigationTUI has the sections a (0-1), b (2-4) and c (5).
+func navigationTUI(cursor int) *tui {
+	return &tui{
+		cursor: cursor,
+		items:  make([]tuiItem, 6),
+		titles: []string{"a", "a", "b", "b", "b", "c"},
+	}
+}
+
+func TestTUIJump(t *testing.T) {
+	cases := []struct {
+		cursor    int
+		direction int
+		want      int
+	}{
+		{0, 1, 2},
+		{1, 1, 2},
+		{3, 1, 5},
+		{5, 1, 0},
+		{3, -1, 2},
+		{2, -1, 0},
+		{1, -1, 0},
+		{0, -1, 5},
+		{5, -1, 2},
+	}
+
+	for _, tc := range cases {
+		ui := navigationTUI(tc.cursor)
+
+		ui.jump(tc.direction)
+
+		if ui.cursor != tc.want {
+			t.Errorf("jump(%d) from %d: got %d, want %d", tc.direction, tc.cursor, ui.cursor, tc.want)
+		}
+	}
+}
+
+func TestTUIHandle(t *testing.T) {
+	cases := []struct {
+		name   string
+		keys   []string
+		cursor int
+		done   bool
+		err    error
+	}{
+		{"down", []string{"j"}, 1, false, nil},
+		{"up-wraps", []string{"\x1b[A"}, 5, false, nil},
+		{"next-section", []string{"\t"}, 2, false, nil},
+		{"previous-section", []string{"\x1b[Z"}, 5, false, nil},
+		{"quit", []string{"q"}, 0, true, errInteractiveAborted},
+		{"interrupt", []string{"\x03"}, 0, true, errInteractiveAborted},
+		{"lone-escape", []string{"\x1b"}, 0, false, nil},
+		{"split-escape", []string{"\x1b", "[B"}, 0, false, nil},
+		{"done", []string{"d"}, 0, true, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ui := navigationTUI(0)
+
+			var (
+				done bool
+				err  error
+			)
+
+			for _, key := range tc.keys {
+				done, err = ui.handle(key)
+				if done {
+					break
+				}
+			}
+
+			if done != tc.done || !errors.Is(err, tc.err) {
+				t.Errorf("got done=%v err=%v, want done=%v err=%v", done, err, tc.done, tc.err)
+			}
+
+			if ui.cursor != tc.cursor {
+				t.Errorf("cursor at %d, want %d", ui.cursor, tc.cursor)
+			}
+		})
+	}
+
+	ui := navigationTUI(0)
+	ui.err = errors.New("invalid")
+
+	if done, _ := ui.handle("d"); done || ui.message == "" {
+		t.Error("expected d to be refused while the config is invalid")
+	}
+}
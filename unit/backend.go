@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// DefaultBackend is used when svc.yml does not name a backend.
+const DefaultBackend = "systemd"
+
+// Service manager actions run after files change, see Backend.Commands.
+const (
+	ActionReload  = "reload"
+	ActionRestart = "restart"
+	ActionDisable = "disable"
+)
+
+// Backend renders a ServiceConfig for one init system.
+type Backend interface {
+	// Name is the value of the backend key in svc.yml.
+	Name() string
+
+	// Templates lists the artifacts written to the conf directory next to
+	// svc.yml. Templates that render nothing are skipped.
+	Templates() []ArtifactTemplate
+
+	// InstalledFiles returns the system files setup.sh installs, keyed by the
+	// name of their copy in a backup.
+	InstalledFiles(cfg *ServiceConfig) map[string]string
+
+	// Unsupported lists the hardening of cfg the init system cannot express.
+	Unsupported(cfg *ServiceConfig) []string
+
+	// Commands returns the commands that perform action for the service.
+	Commands(name, action string) [][]string
+}
+
+type ArtifactTemplate struct {
+	// Kind is the artifact name accepted by mksvc render.
+	Kind string
+
+	// File is the file name in the conf directory, {name} is replaced by the
+	// service name.
+	File string
+
+	Tmpl *template.Template
+}
+
+var backends = []Backend{
+	systemdBackend{},
+	systemdUserBackend{},
+	openrcBackend{},
+	quadletBackend{},
+}
+
+// Backends returns the names of all known backends.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+
+	for _, backend := range backends {
+		names = append(names, backend.Name())
+	}
+
+	return names
+}
+
+func LookupBackend(name string) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	index := slices.IndexFunc(backends, func(backend Backend) bool {
+		return backend.Name() == name
+	})
+
+	if index == -1 {
+		return nil, fmt.Errorf("unknown backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+
+	return backends[index], nil
+}
+
+type systemdBackend struct{}
+
+func (systemdBackend) Name() string {
+	return "systemd"
+}
+
+func (systemdBackend) Templates() []ArtifactTemplate {
+	return []ArtifactTemplate{
+		{"service", "{name}.service", ServiceTmpl},
+		{"sysusers", "{name}.conf", UserTmpl},
+		{"setup", "setup.sh", SetupTmpl},
+		{"uninstall", "uninstall.sh", UninstallTmpl},
+		{"logrotate", "{name}_logs.conf", LogrotateTmpl},
+		{"udev", "99-{name}.rules", UdevTmpl},
+		{"notify-unit", NotifyUnit, NotifyTmpl},
+		{"notify-env", "{name}_notify.env", NotifyEnvTmpl},
+	}
+}
+
+func (systemdBackend) InstalledFiles(cfg *ServiceConfig) map[string]string {
+	return map[string]string{
+		"unit":      "/etc/systemd/system/" + cfg.Name + ".service",
+		"sysusers":  "/etc/sysusers.d/" + cfg.Name + ".conf",
+		"logrotate": "/etc/logrotate.d/" + cfg.Name,
+		"udev":      "/etc/udev/rules.d/99-" + cfg.Name + ".rules",
+		"notify":    "/etc/mksvc/notify.d/" + cfg.Name + ".env",
+
+		// Shared by every service with notify_on_failure
+		"notify-unit": "/etc/systemd/system/" + NotifyUnit,
+	}
+}
+
+func (systemdBackend) Unsupported(cfg *ServiceConfig) []string {
+	return nil
+}
+
+func (systemdBackend) Commands(name, action string) [][]string {
+	switch action {
+	case ActionReload:
+		return [][]string{{"systemctl", "daemon-reload"}}
+	case ActionRestart:
+		return [][]string{{"systemctl", "restart", name}}
+	case ActionDisable:
+		return [][]string{{"systemctl", "disable", "--now", name}}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	pathpkg "path"
+	"strconv"
+	"strings"
+)
+
+// BackupRoot is where setup.sh snapshots previously installed files, one
+// directory per service and run.
+const BackupRoot = "/var/backups/mksvc"
+
+// BackupFile is a system file recorded in a backup manifest. Stored names
+// the copy inside the backup directory; it is empty when the file did not
+// exist before setup.sh ran.
+type BackupFile struct {
+	Path   string
+	Stored string
+}
+
+// BackupStat is the previous ownership and mode of a managed path.
+type BackupStat struct {
+	Path string
+	Mode os.FileMode
+	UID  int
+	GID  int
+}
+
+type BackupManifest struct {
+	Files []BackupFile
+	Stats []BackupStat
+}
+
+// InstalledFiles returns the system files setup.sh installs, keyed by the
+// name of their copy in a backup.
+func (cfg *ServiceConfig) InstalledFiles() map[string]string {
+	backend, err := LookupBackend(cfg.Backend)
+	if err != nil {
+		return nil
+	}
+
+	return backend.InstalledFiles(cfg)
+}
+
+// ParseBackupManifest reads a manifest written by setup.sh. Every entry is
+// checked against cfg, so a tampered manifest cannot restore files outside
+// the installed set or change ownership outside the service path.
+func (cfg *ServiceConfig) ParseBackupManifest(data []byte) (*BackupManifest, error) {
+	installed := cfg.InstalledFiles()
+
+	isInstalled := func(path string) bool {
+		for _, file := range installed {
+			if file == path {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var manifest BackupManifest
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		switch {
+		case fields[0] == "file" && len(fields) == 3:
+			if installed[fields[2]] != fields[1] {
+				return nil, fmt.Errorf("line %d: unexpected file %s", i+1, fields[1])
+			}
+
+			manifest.Files = append(manifest.Files, BackupFile{
+				Path:   fields[1],
+				Stored: fields[2],
+			})
+		case fields[0] == "absent" && len(fields) == 2:
+			if !isInstalled(fields[1]) {
+				return nil, fmt.Errorf("line %d: unexpected file %s", i+1, fields[1])
+			}
+
+			manifest.Files = append(manifest.Files, BackupFile{
+				Path: fields[1],
+			})
+		case fields[0] == "stat" && len(fields) == 5:
+			path := fields[1]
+
+			if pathpkg.Clean(path) != path || (path != cfg.Path && !isBelow(path, cfg.Path)) {
+				return nil, fmt.Errorf("line %d: %s is outside of %s", i+1, path, cfg.Path)
+			}
+
+			// Special bits are never restored
+			mode, err := strconv.ParseUint(fields[2], 8, 32)
+			if err != nil || mode > 0777 {
+				return nil, fmt.Errorf("line %d: invalid mode %s", i+1, fields[2])
+			}
+
+			uid, err := strconv.Atoi(fields[3])
+			if err != nil || uid < 0 {
+				return nil, fmt.Errorf("line %d: invalid uid %s", i+1, fields[3])
+			}
+
+			gid, err := strconv.Atoi(fields[4])
+			if err != nil || gid < 0 {
+				return nil, fmt.Errorf("line %d: invalid gid %s", i+1, fields[4])
+			}
+
+			manifest.Stats = append(manifest.Stats, BackupStat{
+				Path: path,
+				Mode: os.FileMode(mode),
+				UID:  uid,
+				GID:  gid,
+			})
+		default:
+			return nil, fmt.Errorf("line %d: invalid manifest entry %q", i+1, line)
+		}
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("manifest lists no installed files")
+	}
+
+	return &manifest, nil
+}
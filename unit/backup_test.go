@@ -0,0 +1,71 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+)
+
+const testManifest = `# mksvc backup of example
+file /etc/systemd/system/example.service unit
+file /etc/sysusers.d/example.conf sysusers
+file /etc/systemd/system/mksvc-notify@.service notify-unit
+absent /etc/logrotate.d/example
+stat /opt/example 755 0 0
+stat /opt/example/logs 750 998 998
+`
+
+func TestParseBackupManifest(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+
+	manifest, err := cfg.ParseBackupManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Files) != 4 || manifest.Files[3].Stored != "" {
+		t.Errorf("unexpected files: %+v", manifest.Files)
+	}
+
+	if len(manifest.Stats) != 2 || manifest.Stats[1].Mode != 0750 || manifest.Stats[1].UID != 998 {
+		t.Errorf("unexpected stats: %+v", manifest.Stats)
+	}
+
+	tampered := map[string]string{
+		"foreign file":      "file /etc/shadow unit\n",
+		"mismatched stored": "file /etc/sysusers.d/example.conf unit\n",
+		"other notify unit": "file /etc/systemd/system/mksvc-notify@.service.d/x.conf notify-unit\n",
+		"foreign absent":    "absent /etc/passwd\n",
+		"outside path":      "stat /etc 755 0 0\n",
+		"traversal":         "stat /opt/example/../../etc 755 0 0\n",
+		"sibling":           "stat /opt/example2 755 0 0\n",
+		"setuid":            "stat /opt/example/example 4755 0 0\n",
+		"bad uid":           "stat /opt/example 755 x 0\n",
+		"unknown entry":     "exec /bin/sh\n",
+	}
+
+	for name, line := range tampered {
+		_, err := cfg.ParseBackupManifest([]byte(strings.Replace(testManifest, "absent /etc/logrotate.d/example\n", line, 1)))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseBackupManifestOpenRC(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Backend = "openrc"
+
+	manifest, err := cfg.ParseBackupManifest([]byte("file /etc/init.d/example unit\nabsent /etc/conf.d/example\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Files) != 2 {
+		t.Errorf("unexpected files: %+v", manifest.Files)
+	}
+
+	_, err = cfg.ParseBackupManifest([]byte(testManifest))
+	if err == nil {
+		t.Error("expected systemd files to be rejected for the openrc backend")
+	}
+}
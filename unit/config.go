@@ -0,0 +1,755 @@
+// Package unit models, validates and renders hardened systemd services.
+package unit
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"os"
+	pathpkg "path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-yaml"
+)
+
+var (
+	serviceNameRgx = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,30}$`)
+	safePathRgx    = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+	cpuQuotaRgx    = regexp.MustCompile(`^[1-9][0-9]*(?:\.[0-9]+)?%$`)
+	memoryMaxRgx   = regexp.MustCompile(`^[1-9][0-9]*(?:\.[0-9]+)?[KMGTPE]?$`)
+	configFileRgx  = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+	groupNameRgx   = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+	memoryUnits = map[byte]float64{
+		'K': 1 << 10,
+		'M': 1 << 20,
+		'G': 1 << 30,
+		'T': 1 << 40,
+		'P': 1 << 50,
+		'E': 1 << 60,
+	}
+
+	defaultAllowedRoots = []string{
+		"/opt",
+		"/srv",
+		"/var/lib",
+		"/usr/local/lib",
+	}
+
+	// Groups that amount to root access or access to other users' data
+	privilegedGroups = map[string]bool{
+		"root":            true,
+		"wheel":           true,
+		"sudo":            true,
+		"admin":           true,
+		"shadow":          true,
+		"disk":            true,
+		"kmem":            true,
+		"docker":          true,
+		"lxd":             true,
+		"libvirt":         true,
+		"adm":             true,
+		"systemd-journal": true,
+	}
+
+	systemPaths = []string{
+		"/var/lib/apt",
+		"/var/lib/dnf",
+		"/var/lib/dpkg",
+		"/var/lib/polkit-1",
+		"/var/lib/private",
+		"/var/lib/rpm",
+		"/var/lib/sudo",
+		"/var/lib/systemd",
+		"/usr/local/lib/systemd",
+	}
+)
+
+type ServiceConfig struct {
+	Version int `yaml:"version" json:"version"`
+
+	Name  string `yaml:"name" json:"name"`
+	Path  string `yaml:"path" json:"path"`
+	Label string `yaml:"-" json:"-"`
+
+	// Init system (empty = systemd)
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+	Image   string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Core options
+	Network         bool     `yaml:"network" json:"network"`
+	Listening       bool     `yaml:"listening" json:"listening"`
+	PrivilegedPorts bool     `yaml:"privileged_ports" json:"privileged_ports"`
+	ExecMemory      bool     `yaml:"exec_memory" json:"exec_memory"`
+	WritableFiles   bool     `yaml:"writable_files" json:"writable_files"`
+	WritableConfig  bool     `yaml:"writable_config" json:"writable_config"`
+	ConfigFile      string   `yaml:"config_file,omitempty" json:"config_file,omitempty"`
+	RuntimeDir      bool     `yaml:"runtime_dir" json:"runtime_dir"`
+	Devices         bool     `yaml:"devices" json:"devices"`
+	FullDevices     bool     `yaml:"full_devices" json:"full_devices"`
+	AllowedDevices  []Device `yaml:"allowed_devices,omitempty" json:"allowed_devices,omitempty"`
+	Subprocess      bool     `yaml:"subprocess" json:"subprocess"`
+	SeparateLogDir  bool     `yaml:"separate_log_dir" json:"separate_log_dir"`
+
+	// Advanced security
+	LocalhostOnly bool `yaml:"localhost_only" json:"localhost_only"`
+	PrivateUsers  bool `yaml:"private_users" json:"private_users"`
+
+	// Resource limits (empty = no limit)
+	CPUQuota  string `yaml:"cpu_quota,omitempty" json:"cpu_quota,omitempty"`
+	MemoryMax string `yaml:"memory_max,omitempty" json:"memory_max,omitempty"`
+
+	// Environment
+	EnvFile string `yaml:"env_file,omitempty" json:"env_file,omitempty"`
+
+	// Supplementary groups (must already exist)
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+
+	// Dependencies on other units (bare names mean <name>.service)
+	DependsOn []string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	Wants     []string `yaml:"wants,omitempty" json:"wants,omitempty"`
+	BindsTo   []string `yaml:"binds_to,omitempty" json:"binds_to,omitempty"`
+	Before    []string `yaml:"before,omitempty" json:"before,omitempty"`
+
+	// Restart policy (empty = on-failure after 3s, at most 10 starts per 60s)
+	Restart *RestartPolicy `yaml:"restart,omitempty" json:"restart,omitempty"`
+
+	// Failure notifications (sent by mksvc-notify@.service)
+	NotifyOnFailure *FailureNotify `yaml:"notify_on_failure,omitempty" json:"notify_on_failure,omitempty"`
+
+	// Installation
+	Health *HealthCheck `yaml:"health,omitempty" json:"health,omitempty"`
+
+	// Internal (not persisted)
+	After        string              `yaml:"-" json:"-"`
+	Requires     string              `yaml:"-" json:"-"`
+	Defaults     map[string]string   `yaml:"-" json:"-"`
+	Custom       map[string][]string `yaml:"-" json:"-"`
+	AllowedRoots []string            `yaml:"-" json:"-"`
+	Portable     bool                `yaml:"-" json:"-"`
+	Migrations   []string            `yaml:"-" json:"-"`
+}
+
+func NewServiceConfig(name, path string) *ServiceConfig {
+	cleanName := CleanServiceName(name)
+
+	cfg := &ServiceConfig{
+		Version: ConfigVersion,
+
+		Name: cleanName,
+		Path: path,
+
+		Network:         false,
+		Listening:       false,
+		PrivilegedPorts: false,
+		ExecMemory:      false,
+		WritableFiles:   false,
+		WritableConfig:  false,
+		RuntimeDir:      false,
+		Devices:         false,
+		FullDevices:     false,
+		Subprocess:      false,
+		SeparateLogDir:  true,
+
+		LocalhostOnly: false,
+		PrivateUsers:  false,
+
+		CPUQuota:  "",
+		MemoryMax: "",
+
+		Defaults: defaultLimits(),
+		Custom:   make(map[string][]string),
+	}
+
+	cfg.UpdateLabel()
+
+	return cfg
+}
+
+// Clone returns a deep copy of cfg that Prepare can run on without changing
+// cfg.
+func (cfg *ServiceConfig) Clone() *ServiceConfig {
+	clone := *cfg
+
+	clone.AllowedDevices = slices.Clone(cfg.AllowedDevices)
+	clone.Groups = slices.Clone(cfg.Groups)
+	clone.DependsOn = slices.Clone(cfg.DependsOn)
+	clone.Wants = slices.Clone(cfg.Wants)
+	clone.BindsTo = slices.Clone(cfg.BindsTo)
+	clone.Before = slices.Clone(cfg.Before)
+	clone.AllowedRoots = slices.Clone(cfg.AllowedRoots)
+	clone.Migrations = slices.Clone(cfg.Migrations)
+
+	clone.Defaults = maps.Clone(cfg.Defaults)
+
+	if cfg.Custom != nil {
+		clone.Custom = make(map[string][]string, len(cfg.Custom))
+
+		for key, values := range cfg.Custom {
+			clone.Custom[key] = slices.Clone(values)
+		}
+	}
+
+	if cfg.Health != nil {
+		health := *cfg.Health
+		clone.Health = &health
+	}
+
+	if cfg.Restart != nil {
+		restart := *cfg.Restart
+		restart.SuccessExitStatus = slices.Clone(cfg.Restart.SuccessExitStatus)
+		restart.PreventExitStatus = slices.Clone(cfg.Restart.PreventExitStatus)
+		restart.OnFailure = slices.Clone(cfg.Restart.OnFailure)
+		clone.Restart = &restart
+	}
+
+	if cfg.NotifyOnFailure != nil {
+		notify := *cfg.NotifyOnFailure
+		clone.NotifyOnFailure = &notify
+	}
+
+	return &clone
+}
+
+func LoadConfig(path string) (*ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+func ParseConfig(data []byte) (*ServiceConfig, error) {
+	data, changes, err := migrateConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg ServiceConfig
+
+	if err := yaml.UnmarshalWithOptions(data, &cfg, yaml.Strict()); err != nil {
+		return nil, err
+	}
+
+	cfg.Migrations = changes
+
+	cfg.Defaults = defaultLimits()
+	cfg.Custom = make(map[string][]string)
+	cfg.UpdateLabel()
+
+	return &cfg, nil
+}
+
+// Prepare runs the generation pipeline on a fully configured cfg: it
+// normalizes and validates the options, preserves custom directives from the
+// existing unit at servicePath (if any, an empty path skips preservation) and
+// applies derived defaults. The returned warnings are informational.
+func (cfg *ServiceConfig) Prepare(servicePath string) ([]string, error) {
+	cfg.Normalize()
+
+	var warnings []string
+
+	if cfg.PrivateUsers {
+		allowed, reason := cfg.CanHavePrivateUsers()
+
+		if !allowed {
+			cfg.PrivateUsers = false
+
+			warnings = append(warnings, "private_users "+reason)
+		}
+	}
+
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if servicePath != "" {
+		if err := cfg.PreserveCustom(servicePath); err != nil {
+			return nil, fmt.Errorf("could not preserve existing service configuration: %w", err)
+		}
+	}
+
+	cfg.ApplyDefaultAfter()
+
+	warnings = append(warnings, cfg.Warnings()...)
+
+	backend, _ := LookupBackend(cfg.Backend)
+
+	for _, setting := range backend.Unsupported(cfg) {
+		warnings = append(warnings, backend.Name()+" cannot express "+setting)
+	}
+
+	return warnings, nil
+}
+
+func (cfg *ServiceConfig) Normalize() {
+	if !cfg.Network {
+		cfg.Listening = false
+		cfg.PrivilegedPorts = false
+		cfg.LocalhostOnly = false
+	}
+
+	if !cfg.Listening {
+		cfg.PrivilegedPorts = false
+	}
+
+	if !cfg.Devices {
+		cfg.FullDevices = false
+	}
+
+	// User managers can only sandbox inside a user namespace
+	if cfg.UserMode() {
+		cfg.PrivateUsers = true
+	}
+
+	if cfg.Health != nil && cfg.Health.Timeout == 0 {
+		cfg.Health.Timeout = defaultHealthTimeout
+	}
+
+	if cfg.Restart != nil {
+		cfg.Restart.Normalize()
+	}
+
+	if cfg.NotifyOnFailure != nil && cfg.NotifyOnFailure.Lines == 0 {
+		cfg.NotifyOnFailure.Lines = defaultNotifyLines
+	}
+}
+
+func (cfg *ServiceConfig) Validate() error {
+	if !serviceNameRgx.MatchString(cfg.Name) || cfg.Name == "root" || cfg.Name == "nobody" {
+		return fmt.Errorf("invalid service name %q", cfg.Name)
+	}
+
+	if err := cfg.ValidatePath(); err != nil {
+		return err
+	}
+
+	if _, err := LookupBackend(cfg.Backend); err != nil {
+		return err
+	}
+
+	if cfg.Backend == "quadlet" {
+		if !imageRgx.MatchString(cfg.Image) {
+			return fmt.Errorf("the quadlet backend needs a valid image, got %q", cfg.Image)
+		}
+	} else if cfg.Image != "" {
+		return fmt.Errorf("image requires the quadlet backend")
+	}
+
+	if cfg.UserMode() {
+		if cfg.PrivilegedPorts {
+			return fmt.Errorf("privileged_ports is not available to user services")
+		}
+
+		if cfg.Devices {
+			return fmt.Errorf("devices is not available to user services")
+		}
+	}
+
+	if len(cfg.AllowedDevices) > 0 {
+		if !cfg.Devices {
+			return fmt.Errorf("allowed_devices requires devices")
+		}
+
+		if cfg.FullDevices {
+			return fmt.Errorf("allowed_devices cannot be combined with full_devices, which allows every device")
+		}
+
+		if backend, _ := LookupBackend(cfg.Backend); backend.Name() != DefaultBackend {
+			return fmt.Errorf("allowed_devices requires the systemd backend")
+		}
+
+		for _, device := range cfg.AllowedDevices {
+			if err := device.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := cfg.validateGroups(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateDependencies(); err != nil {
+		return err
+	}
+
+	if cfg.EnvFile != "" && !validAbsolutePath(cfg.EnvFile) {
+		return fmt.Errorf("invalid environment file path %q", cfg.EnvFile)
+	}
+
+	if cfg.CPUQuota != "" && !cpuQuotaRgx.MatchString(cfg.CPUQuota) {
+		return fmt.Errorf("invalid CPU quota %q", cfg.CPUQuota)
+	}
+
+	if cfg.MemoryMax != "" && !memoryMaxRgx.MatchString(cfg.MemoryMax) {
+		return fmt.Errorf("invalid memory limit %q", cfg.MemoryMax)
+	}
+
+	if cfg.WritableConfig {
+		if !configFileRgx.MatchString(cfg.ConfigFile) || cfg.ConfigFile == "." || cfg.ConfigFile == ".." {
+			return fmt.Errorf("invalid writable config filename %q", cfg.ConfigFile)
+		}
+
+		reserved := map[string]bool{
+			cfg.Name:          true,
+			cfg.Name + ".log": true,
+			"conf":            true,
+			"data":            true,
+			"logs":            true,
+			ReleaseDir:        true,
+		}
+
+		if reserved[cfg.ConfigFile] {
+			return fmt.Errorf("writable config filename %q conflicts with a managed path", cfg.ConfigFile)
+		}
+	} else if cfg.ConfigFile != "" {
+		return fmt.Errorf("config_file requires writable_config")
+	}
+
+	if cfg.Health != nil {
+		if err := cfg.Health.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Restart != nil {
+		if err := cfg.Restart.Validate(cfg.Name); err != nil {
+			return err
+		}
+	}
+
+	if cfg.NotifyOnFailure != nil {
+		if backend, _ := LookupBackend(cfg.Backend); backend.Name() != DefaultBackend {
+			return fmt.Errorf("notify_on_failure requires the systemd backend")
+		}
+
+		if err := cfg.NotifyOnFailure.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *ServiceConfig) validateGroups() error {
+	if len(cfg.Groups) == 0 {
+		return nil
+	}
+
+	switch {
+	case cfg.UserMode():
+		return fmt.Errorf("groups is not available to user services")
+	case cfg.Backend == "quadlet":
+		return fmt.Errorf("groups is not supported by the quadlet backend")
+	}
+
+	seen := make(map[string]bool, len(cfg.Groups))
+
+	for _, group := range cfg.Groups {
+		if !groupNameRgx.MatchString(group) {
+			return fmt.Errorf("invalid group name %q", group)
+		}
+
+		if group == cfg.Name {
+			return fmt.Errorf("group %q is the service's own group", group)
+		}
+
+		if privilegedGroups[group] {
+			return fmt.Errorf("refusing privileged group %q", group)
+		}
+
+		if seen[group] {
+			return fmt.Errorf("duplicate group %q", group)
+		}
+
+		seen[group] = true
+	}
+
+	return nil
+}
+
+func (cfg *ServiceConfig) ValidatePath() error {
+	roots := cfg.AllowedRoots
+	if len(roots) == 0 {
+		roots = defaultAllowedRoots
+
+		if cfg.UserMode() {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("user services live below your home directory: %w", err)
+			}
+
+			roots = []string{home}
+		}
+	}
+
+	if !validAbsolutePath(cfg.Path) {
+		return fmt.Errorf("invalid service path %q: must be a clean absolute path using only [A-Za-z0-9._/-]", cfg.Path)
+	}
+
+	var allowed bool
+
+	for _, root := range roots {
+		if !validAbsolutePath(root) {
+			return fmt.Errorf("invalid allowed root %q", root)
+		}
+
+		if isBelow(cfg.Path, root) {
+			allowed = true
+
+			break
+		}
+	}
+
+	if !allowed {
+		return fmt.Errorf("invalid service path %q: must be below one of %s", cfg.Path, strings.Join(roots, ", "))
+	}
+
+	for _, system := range systemPaths {
+		if cfg.Path == system || isBelow(cfg.Path, system) || isBelow(system, cfg.Path) {
+			return fmt.Errorf("invalid service path %q: collides with system directory %s", cfg.Path, system)
+		}
+	}
+
+	link, err := symlinkComponent(cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	if link != "" {
+		return fmt.Errorf("invalid service path %q: %s is a symlink", cfg.Path, link)
+	}
+
+	return nil
+}
+
+func (cfg *ServiceConfig) SaveConfig(path string) error {
+	data, err := cfg.MarshalConfig()
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, data, 0600)
+}
+
+// MarshalConfig encodes cfg as svc.yml at the current schema version
+// without changing cfg.
+func (cfg *ServiceConfig) MarshalConfig() ([]byte, error) {
+	saved := *cfg
+	saved.Version = ConfigVersion
+
+	data, err := yaml.Marshal(&saved)
+	if err != nil {
+		return nil, err
+	}
+
+	header := "# yaml-language-server: $schema=" + SchemaURL + "\n"
+
+	return append([]byte(header), data...), nil
+}
+
+func (cfg *ServiceConfig) UpdateLabel() {
+	words := strings.FieldsFunc(cfg.Name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-'
+	})
+
+	for i, word := range words {
+		runes := []rune(word)
+
+		if len(runes) > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+
+			words[i] = string(runes)
+		}
+	}
+
+	cfg.Label = strings.Join(words, " ")
+}
+
+func (cfg *ServiceConfig) ApplyDefaultAfter() {
+	var afters, requires []string
+
+	switch {
+	case cfg.UserMode():
+		// System targets are not visible to user managers
+	case cfg.Network:
+		target := "network.target"
+
+		if cfg.Listening {
+			target = "network-online.target"
+		}
+
+		afters = append(afters, target)
+		requires = append(requires, target)
+	default:
+		afters = append(afters, "local-fs.target")
+	}
+
+	cfg.After = strings.TrimSpace(prependUnique(strings.FieldsSeq(cfg.After), afters))
+	cfg.Requires = strings.TrimSpace(prependUnique(strings.FieldsSeq(cfg.Requires), requires))
+
+	ordered := slices.Concat(cfg.DependsOnUnits(), cfg.WantsUnits(), cfg.BindsToUnits())
+
+	cfg.After = strings.TrimSpace(prependUnique(slices.Values(ordered), strings.Fields(cfg.After)))
+	cfg.Requires = strings.TrimSpace(prependUnique(slices.Values(cfg.DependsOnUnits()), strings.Fields(cfg.Requires)))
+}
+
+func (cfg *ServiceConfig) CanHavePrivateUsers() (bool, string) {
+	if cfg.PrivilegedPorts {
+		return false, "disabled because privileged ports require CAP_NET_BIND_SERVICE"
+	}
+
+	if cfg.Devices {
+		return false, "disabled because device access + supplementary groups are enabled"
+	}
+
+	if len(cfg.Groups) > 0 {
+		return false, "disabled because supplementary groups are set"
+	}
+
+	return true, ""
+}
+
+func (cfg *ServiceConfig) Warnings() []string {
+	var warnings []string
+
+	if cfg.FullDevices {
+		warnings = append(warnings, "full_devices disables device sandboxing (DevicePolicy=none)")
+	}
+
+	if cfg.PrivilegedPorts {
+		warnings = append(warnings, "privileged_ports grants CAP_NET_BIND_SERVICE to the service")
+	}
+
+	if cfg.ExecMemory {
+		warnings = append(warnings, "exec_memory disables MemoryDenyWriteExecute")
+	}
+
+	if cfg.Restart != nil {
+		warnings = append(warnings, cfg.Restart.Warnings()...)
+	}
+
+	return warnings
+}
+
+// cpuPercent returns the validated CPU quota as a number, 150 for "150%".
+func (cfg *ServiceConfig) cpuPercent() float64 {
+	percent, _ := strconv.ParseFloat(strings.TrimSuffix(cfg.CPUQuota, "%"), 64)
+
+	return percent
+}
+
+// memoryMaxBytes returns the validated memory limit in bytes, using the same
+// 1024-based suffixes as systemd.
+func (cfg *ServiceConfig) memoryMaxBytes() int64 {
+	value := cfg.MemoryMax
+	unit := 1.0
+
+	if factor, ok := memoryUnits[value[len(value)-1]]; ok {
+		value = value[:len(value)-1]
+		unit = factor
+	}
+
+	size, _ := strconv.ParseFloat(value, 64)
+
+	return int64(size * unit)
+}
+
+func validAbsolutePath(value string) bool {
+	return safePathRgx.MatchString(value) && pathpkg.IsAbs(value) && pathpkg.Clean(value) == value
+}
+
+func isBelow(value, root string) bool {
+	return strings.HasPrefix(value, strings.TrimSuffix(root, "/")+"/")
+}
+
+func symlinkComponent(value string) (string, error) {
+	current := "/"
+
+	for part := range strings.SplitSeq(strings.Trim(value, "/"), "/") {
+		current = pathpkg.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", nil
+			}
+
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return current, nil
+		}
+	}
+
+	return "", nil
+}
+
+func defaultLimits() map[string]string {
+	return map[string]string{
+		"LimitNOFILE":     "65536",
+		"LimitNPROC":      "4096",
+		"LimitCORE":       "0",
+		"TimeoutStartSec": "300",
+		"TimeoutStopSec":  "300",
+	}
+}
+
+func prependUnique(existing iter.Seq[string], defaults []string) string {
+	found := make(map[string]bool)
+
+	var result []string
+
+	for _, str := range defaults {
+		if !found[str] {
+			found[str] = true
+
+			result = append(result, str)
+		}
+	}
+
+	for str := range existing {
+		if !found[str] && str != "" {
+			found[str] = true
+
+			result = append(result, str)
+		}
+	}
+
+	return strings.Join(result, " ")
+}
+
+func CleanServiceName(name string) string {
+	name = strings.ToLower(name)
+
+	name = strings.ReplaceAll(name, ".", "_")
+	name = strings.ReplaceAll(name, " ", "_")
+
+	reg := regexp.MustCompile(`[^a-z0-9_-]`)
+	name = reg.ReplaceAllString(name, "")
+
+	// Names may not start with a dash and are limited to 31 characters
+	name = strings.TrimLeft(name, "-")
+
+	if len(name) > 0 && unicode.IsDigit(rune(name[0])) {
+		name = "svc_" + name
+	}
+
+	if len(name) > 31 {
+		name = name[:31]
+	}
+
+	if name == "" {
+		name = "service"
+	}
+
+	return name
+}
@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzCleanServiceName(f *testing.F) {
+	f.Add("my-app")
+	f.Add("My App.v2")
+	f.Add("123")
+	f.Add("")
+	f.Add("../../etc/passwd")
+	f.Add("İstanbul")
+	f.Add("-foo")
+	f.Add(strings.Repeat("a", 40))
+	f.Add("--1" + strings.Repeat("b", 40))
+
+	f.Fuzz(func(t *testing.T, name string) {
+		clean := CleanServiceName(name)
+
+		if !serviceNameRgx.MatchString(clean) {
+			t.Fatalf("CleanServiceName(%q) = %q is not a valid service name", name, clean)
+		}
+
+		if again := CleanServiceName(clean); again != clean {
+			t.Fatalf("CleanServiceName is not idempotent: %q -> %q -> %q", name, clean, again)
+		}
+	})
+}
+
+func TestGroups(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+
+	for _, groups := range [][]string{{"Media"}, {"media group"}, {"example"}, {"sudo"}, {"lxd"}, {"libvirt"}, {"adm"}, {"systemd-journal"}, {"media", "media"}} {
+		cfg.Groups = groups
+
+		if cfg.Validate() == nil {
+			t.Errorf("expected groups %q to be rejected", groups)
+		}
+	}
+
+	cfg.Groups = []string{"ssl-cert", "media"}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid groups: %v", err)
+	}
+
+	if allowed, _ := cfg.CanHavePrivateUsers(); allowed {
+		t.Error("expected supplementary groups to rule out private users")
+	}
+
+	cfg.Backend = "quadlet"
+	cfg.Image = "example:latest"
+
+	if cfg.Validate() == nil {
+		t.Error("expected groups to be rejected for the quadlet backend")
+	}
+}
+
+func TestConfigFileReserved(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.WritableConfig = true
+
+	for _, name := range []string{"example", "example.log", "conf", "data", "logs", "releases"} {
+		cfg.ConfigFile = name
+
+		if cfg.Validate() == nil {
+			t.Errorf("expected config_file %q to be rejected", name)
+		}
+	}
+
+	cfg.ConfigFile = "config.yml"
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config_file: %v", err)
+	}
+}
+
+func TestMarshalConfigKeepsConfig(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Version = 1
+
+	data, err := cfg.MarshalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Version != 1 {
+		t.Fatalf("MarshalConfig changed Version to %d", cfg.Version)
+	}
+
+	if !strings.Contains(string(data), "version: "+strconv.Itoa(ConfigVersion)+"\n") {
+		t.Fatalf("saved config is not at version %d:\n%s", ConfigVersion, data)
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Devices = true
+	cfg.AllowedDevices = []Device{{Path: "/dev/ttyUSB0"}}
+	cfg.Groups = []string{"dialout"}
+	cfg.DependsOn = []string{"postgresql"}
+	cfg.Wants = []string{"redis"}
+	cfg.BindsTo = []string{"vpn"}
+	cfg.Before = []string{"worker"}
+	cfg.Custom["Environment"] = []string{"A=1"}
+	cfg.Restart = &RestartPolicy{
+		SuccessExitStatus: []string{"SIGTERM"},
+		PreventExitStatus: []string{"2"},
+		OnFailure:         []string{"alert.service"},
+	}
+
+	before, err := cfg.MarshalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := cfg.Clone()
+
+	clone.AllowedDevices[0].Path = "/dev/ttyACM0"
+	clone.Groups[0] = "video"
+	clone.DependsOn[0] = "mysql"
+	clone.Wants[0] = "memcached"
+	clone.BindsTo[0] = "wireguard"
+	clone.Before[0] = "cron"
+	clone.Custom["Environment"][0] = "A=2"
+	clone.Defaults["LimitNOFILE"] = "1024"
+	clone.Restart.SuccessExitStatus[0] = "SIGINT"
+	clone.Restart.PreventExitStatus[0] = "3"
+	clone.Restart.OnFailure[0] = "other.service"
+
+	after, err := cfg.MarshalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(before) != string(after) {
+		t.Errorf("changing the clone changed the config:\n%s", after)
+	}
+
+	if cfg.Custom["Environment"][0] != "A=1" || cfg.Defaults["LimitNOFILE"] != "65536" {
+		t.Errorf("changing the clone changed custom or default directives: %v %v", cfg.Custom, cfg.Defaults)
+	}
+}
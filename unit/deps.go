@@ -0,0 +1,278 @@
+package unit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/parser"
+)
+
+var (
+	dependencyRgx = regexp.MustCompile(`^[A-Za-z0-9:_.@-]+\.(?:service|socket|target|mount|path|timer|device)$`)
+
+	// Lists the dependency units of the previous render, so preservation
+	// can tell them apart from hand edits
+	dependencyMarkerRgx = regexp.MustCompile(`(?m)^# Dependencies from svc\.yml: (.+)$`)
+)
+
+// dependencyUnit expands a bare service name such as "queue" to
+// "queue.service".
+func dependencyUnit(name string) string {
+	if dependencyRgx.MatchString(name) {
+		return name
+	}
+
+	return name + ".service"
+}
+
+func dependencyUnits(names []string) []string {
+	units := make([]string, 0, len(names))
+
+	for _, name := range names {
+		units = append(units, dependencyUnit(name))
+	}
+
+	return units
+}
+
+// DependsOnUnits returns the units of depends_on (After= and Requires=).
+func (cfg *ServiceConfig) DependsOnUnits() []string {
+	return dependencyUnits(cfg.DependsOn)
+}
+
+// WantsUnits returns the units of wants (After= and Wants=).
+func (cfg *ServiceConfig) WantsUnits() []string {
+	return dependencyUnits(cfg.Wants)
+}
+
+// BindsToUnits returns the units of binds_to (After= and BindsTo=).
+func (cfg *ServiceConfig) BindsToUnits() []string {
+	return dependencyUnits(cfg.BindsTo)
+}
+
+// BeforeUnits returns the units of before (Before=).
+func (cfg *ServiceConfig) BeforeUnits() []string {
+	return dependencyUnits(cfg.Before)
+}
+
+// DependencyUnits returns every unit named by the dependency lists.
+func (cfg *ServiceConfig) DependencyUnits() []string {
+	var units []string
+
+	units = append(units, cfg.DependsOnUnits()...)
+	units = append(units, cfg.WantsUnits()...)
+	units = append(units, cfg.BindsToUnits()...)
+	units = append(units, cfg.BeforeUnits()...)
+
+	return uniqueStrings(units)
+}
+
+func (cfg *ServiceConfig) validateDependencies() error {
+	self := cfg.Name + ".service"
+
+	// Every unit may appear in only one list, which also keeps a unit from
+	// being ordered both before and after the service
+	seen := make(map[string]string)
+
+	lists := []struct {
+		key   string
+		names []string
+	}{
+		{"depends_on", cfg.DependsOn},
+		{"wants", cfg.Wants},
+		{"binds_to", cfg.BindsTo},
+		{"before", cfg.Before},
+	}
+
+	for _, list := range lists {
+		for _, name := range list.names {
+			unit := dependencyUnit(name)
+
+			if !dependencyRgx.MatchString(unit) || strings.HasPrefix(name, "-") {
+				return fmt.Errorf("invalid %s unit %q", list.key, name)
+			}
+
+			if unit == self {
+				return fmt.Errorf("%s cannot name the service itself", list.key)
+			}
+
+			if previous, ok := seen[unit]; ok {
+				if previous == list.key {
+					return fmt.Errorf("duplicate %s unit %q", list.key, unit)
+				}
+
+				return fmt.Errorf("%s is listed in both %s and %s", unit, previous, list.key)
+			}
+
+			seen[unit] = list.key
+
+			if cfg.Backend == "openrc" && !strings.HasSuffix(unit, ".service") {
+				return fmt.Errorf("the openrc backend can only depend on services, got %q", unit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseConfigs parses a multi-service configuration: one svc.yml document
+// per service, separated by "---".
+func ParseConfigs(data []byte) ([]*ServiceConfig, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []*ServiceConfig
+
+	for i, doc := range file.Docs {
+		if doc.Body == nil {
+			continue
+		}
+
+		cfg, err := ParseConfig([]byte(doc.String()))
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	return cfgs, nil
+}
+
+// DependencyOrder sorts services so each one comes after the services it is
+// ordered after (depends_on, wants, binds_to) and before the ones it lists
+// in before. Units that are not part of cfgs are ignored. It fails on
+// invalid dependencies and on ordering cycles.
+func DependencyOrder(cfgs []*ServiceConfig) ([]*ServiceConfig, error) {
+	index := make(map[string]int, len(cfgs))
+
+	for i, cfg := range cfgs {
+		if err := cfg.validateDependencies(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Name, err)
+		}
+
+		unit := cfg.Name + ".service"
+
+		if _, ok := index[unit]; ok {
+			return nil, fmt.Errorf("service %s is defined twice", cfg.Name)
+		}
+
+		index[unit] = i
+	}
+
+	// edges[i] lists the services that have to start after service i
+	edges := make([][]int, len(cfgs))
+
+	for i, cfg := range cfgs {
+		for _, unit := range slices.Concat(cfg.DependsOnUnits(), cfg.WantsUnits(), cfg.BindsToUnits()) {
+			if j, ok := index[unit]; ok {
+				edges[j] = append(edges[j], i)
+			}
+		}
+
+		for _, unit := range cfg.BeforeUnits() {
+			if j, ok := index[unit]; ok {
+				edges[i] = append(edges[i], j)
+			}
+		}
+	}
+
+	if cycle := findCycle(edges); cycle != nil {
+		names := make([]string, 0, len(cycle))
+
+		for _, i := range cycle {
+			names = append(names, cfgs[i].Name)
+		}
+
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(names, " -> "))
+	}
+
+	// Kahn's algorithm, picking the earliest ready service to keep the
+	// input order where possible
+	incoming := make([]int, len(cfgs))
+
+	for _, targets := range edges {
+		for _, j := range targets {
+			incoming[j]++
+		}
+	}
+
+	var (
+		order []*ServiceConfig
+		done  = make([]bool, len(cfgs))
+	)
+
+	for len(order) < len(cfgs) {
+		for i := range cfgs {
+			if done[i] || incoming[i] > 0 {
+				continue
+			}
+
+			done[i] = true
+
+			order = append(order, cfgs[i])
+
+			for _, j := range edges[i] {
+				incoming[j]--
+			}
+
+			break
+		}
+	}
+
+	return order, nil
+}
+
+// findCycle returns the services of one cycle in edges, with the first
+// service repeated at the end, or nil.
+func findCycle(edges [][]int) []int {
+	const (
+		unvisited = iota
+		active
+		finished
+	)
+
+	state := make([]int, len(edges))
+
+	var (
+		stack []int
+		visit func(i int) []int
+	)
+
+	visit = func(i int) []int {
+		state[i] = active
+		stack = append(stack, i)
+
+		for _, j := range edges[i] {
+			switch state[j] {
+			case active:
+				start := slices.Index(stack, j)
+
+				return append(slices.Clone(stack[start:]), j)
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[i] = finished
+
+		return nil
+	}
+
+	for i := range edges {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDependenciesValidate(t *testing.T) {
+	invalid := []func(cfg *ServiceConfig){
+		func(cfg *ServiceConfig) { cfg.DependsOn = []string{"queue service"} },
+		func(cfg *ServiceConfig) { cfg.DependsOn = []string{"-queue"} },
+		func(cfg *ServiceConfig) { cfg.Wants = []string{"example"} },
+		func(cfg *ServiceConfig) { cfg.BindsTo = []string{"queue", "queue.service"} },
+		func(cfg *ServiceConfig) { cfg.DependsOn, cfg.Before = []string{"queue"}, []string{"queue"} },
+		func(cfg *ServiceConfig) { cfg.Backend, cfg.Wants = "openrc", []string{"network-online.target"} },
+	}
+
+	for i, setup := range invalid {
+		cfg := NewServiceConfig("example", "/opt/example")
+
+		setup(cfg)
+
+		if cfg.Validate() == nil {
+			t.Errorf("case %d: expected dependencies to be rejected", i)
+		}
+	}
+
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.DependsOn = []string{"queue", "postgresql.service"}
+	cfg.Wants = []string{"redis.socket"}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"queue.service", "postgresql.service", "redis.socket"}
+
+	if units := cfg.DependencyUnits(); !slices.Equal(units, expected) {
+		t.Errorf("expected units %v, got %v", expected, units)
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	cfgs, err := ParseConfigs([]byte(`version: 2
+name: api
+path: /opt/api
+depends_on: [queue]
+wants: [postgresql.service]
+---
+version: 2
+name: queue
+path: /opt/queue
+before: [worker]
+---
+version: 2
+name: worker
+path: /opt/worker
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := DependencyOrder(cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, cfg := range order {
+		names = append(names, cfg.Name)
+	}
+
+	if expected := []string{"queue", "api", "worker"}; !slices.Equal(names, expected) {
+		t.Errorf("expected order %v, got %v", expected, names)
+	}
+
+	cfgs[2].BindsTo = []string{"api"}
+	cfgs[0].DependsOn = append(cfgs[0].DependsOn, "worker")
+
+	_, err = DependencyOrder(cfgs)
+	if err == nil || !strings.Contains(err.Error(), "api -> worker -> api") {
+		t.Errorf("expected a cycle between api and worker, got %v", err)
+	}
+
+	_, err = DependencyOrder([]*ServiceConfig{cfgs[1], cfgs[1]})
+	if err == nil {
+		t.Error("expected a service defined twice to be rejected")
+	}
+}
+
+func TestDependenciesPreserve(t *testing.T) {
+	servicePath := filepath.Join(t.TempDir(), "example.service")
+
+	render := func(cfg *ServiceConfig) string {
+		if _, err := cfg.Prepare(servicePath); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := cfg.RenderArtifact("service")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(servicePath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.DependsOn = []string{"queue"}
+
+	if unit := render(cfg); !strings.Contains(unit, "Requires=queue.service\n") {
+		t.Fatalf("expected queue.service to be required\n%s", unit)
+	}
+
+	// A dependency removed from svc.yml must not survive as a hand edit
+	cfg = NewServiceConfig("example", "/opt/example")
+
+	if unit := render(cfg); strings.Contains(unit, "queue.service") {
+		t.Errorf("expected queue.service to be dropped\n%s", unit)
+	}
+}
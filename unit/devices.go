@@ -0,0 +1,248 @@
+package unit
+
+import (
+	_ "embed"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	//go:embed templates/udev.tmpl
+	udevStr string
+
+	UdevTmpl = parseTemplate("udev", udevStr)
+
+	usbIDRgx = regexp.MustCompile(`^[0-9a-f]{4}$`)
+
+	// DeviceAllow classes (driver names from /proc/devices) for the udev
+	// subsystems a device entry may name
+	deviceSubsystems = map[string][]string{
+		"tty":         {"char-ttyUSB", "char-ttyACM"},
+		"usb":         {"char-usb_device"},
+		"hidraw":      {"char-hidraw"},
+		"video4linux": {"char-video4linux"},
+		"sound":       {"char-alsa"},
+		"input":       {"char-input"},
+		"gpio":        {"char-gpiochip"},
+		"i2c-dev":     {"char-i2c"},
+		"spidev":      {"char-spi"},
+	}
+
+	// Node names below /dev (path.Match patterns) of those subsystems. Paths
+	// are checked against them so an entry cannot hand raw memory, I/O ports
+	// or block devices to the service.
+	deviceNodes = map[string][]string{
+		"tty":         {"ttyUSB*", "ttyACM*", "ttyS*", "ttyAMA*", "serial/by-id/*", "serial/by-path/*"},
+		"usb":         {"bus/usb/*/*"},
+		"hidraw":      {"hidraw*"},
+		"video4linux": {"video*", "v4l/by-id/*", "v4l/by-path/*"},
+		"sound":       {"snd/*"},
+		"input":       {"input/event*", "input/by-id/*", "input/by-path/*"},
+		"gpio":        {"gpiochip*"},
+		"i2c-dev":     {"i2c-*"},
+		"spidev":      {"spidev*"},
+	}
+
+	// Granted when devices is enabled without an allowed_devices list
+	defaultDeviceAllow = []string{"char-usb rwm", "char-tty rwm"}
+)
+
+// Device selects hardware the service may access, either a device node
+// (Path) or a udev subsystem optionally narrowed to one USB vendor and
+// product. Entries with a path or vendor become udev rules that hand the
+// matching nodes to the service user.
+type Device struct {
+	Subsystem string `yaml:"subsystem,omitempty" json:"subsystem,omitempty"`
+	Vendor    string `yaml:"vendor,omitempty" json:"vendor,omitempty"`
+	Product   string `yaml:"product,omitempty" json:"product,omitempty"`
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+func (d Device) Validate() error {
+	if (d.Path == "") == (d.Subsystem == "") {
+		return fmt.Errorf("device needs exactly one of path or subsystem")
+	}
+
+	if d.Path != "" {
+		if !validAbsolutePath(d.Path) || !isBelow(d.Path, "/dev") || d.Path == "/dev" {
+			return fmt.Errorf("invalid device path %q: must be a node below /dev", d.Path)
+		}
+
+		if !isDeviceNode(d.Path) {
+			return fmt.Errorf("unsupported device path %q: must be a node of a supported subsystem (%s)", d.Path, strings.Join(DeviceSubsystems(), ", "))
+		}
+
+		if d.Vendor != "" || d.Product != "" {
+			return fmt.Errorf("device %s: vendor and product need a subsystem", d.Path)
+		}
+
+		return nil
+	}
+
+	if _, ok := deviceSubsystems[d.Subsystem]; !ok {
+		return fmt.Errorf("unsupported device subsystem %q (available: %s)", d.Subsystem, strings.Join(DeviceSubsystems(), ", "))
+	}
+
+	if d.Vendor != "" && !usbIDRgx.MatchString(d.Vendor) {
+		return fmt.Errorf("invalid vendor id %q: must be 4 lowercase hex digits", d.Vendor)
+	}
+
+	if d.Product != "" {
+		if d.Vendor == "" {
+			return fmt.Errorf("device product %q needs a vendor", d.Product)
+		}
+
+		if !usbIDRgx.MatchString(d.Product) {
+			return fmt.Errorf("invalid product id %q: must be 4 lowercase hex digits", d.Product)
+		}
+	}
+
+	return nil
+}
+
+func (d Device) String() string {
+	if d.Path != "" {
+		return d.Path
+	}
+
+	if d.Vendor == "" {
+		return d.Subsystem
+	}
+
+	if d.Product == "" {
+		return d.Subsystem + " " + d.Vendor
+	}
+
+	return d.Subsystem + " " + d.Vendor + ":" + d.Product
+}
+
+func isDeviceNode(file string) bool {
+	name := strings.TrimPrefix(file, "/dev/")
+
+	for _, patterns := range deviceNodes {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// DeviceSubsystems returns the udev subsystems a device entry may name.
+func DeviceSubsystems() []string {
+	var names []string
+
+	for name := range deviceSubsystems {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// DeviceAllow returns the DeviceAllow values of the unit. Without an
+// allowed_devices list all USB and serial devices are allowed.
+func (cfg *ServiceConfig) DeviceAllow() []string {
+	if !cfg.Devices || cfg.FullDevices {
+		return nil
+	}
+
+	if len(cfg.AllowedDevices) == 0 {
+		return defaultDeviceAllow
+	}
+
+	var values []string
+
+	for _, device := range cfg.AllowedDevices {
+		if device.Path != "" {
+			values = append(values, device.Path+" rw")
+
+			continue
+		}
+
+		for _, class := range deviceSubsystems[device.Subsystem] {
+			values = append(values, class+" rw")
+		}
+	}
+
+	return uniqueStrings(values)
+}
+
+// UdevRules returns one rule per allowed device that can be matched without
+// claiming a whole subsystem.
+func (cfg *ServiceConfig) UdevRules() []string {
+	var rules []string
+
+	owner := fmt.Sprintf(`OWNER="%s", GROUP="%s", MODE="0660"`, cfg.Name, cfg.Name)
+
+	for _, device := range cfg.AllowedDevices {
+		var match string
+
+		switch {
+		case device.Path != "":
+			name := strings.TrimPrefix(device.Path, "/dev/")
+
+			if strings.Contains(name, "/") {
+				match = fmt.Sprintf(`SYMLINK=="%s"`, name)
+			} else {
+				match = fmt.Sprintf(`KERNEL=="%s"`, name)
+			}
+		case device.Vendor != "":
+			match = fmt.Sprintf(`SUBSYSTEM=="%s", ATTRS{idVendor}=="%s"`, device.Subsystem, device.Vendor)
+
+			if device.Product != "" {
+				match += fmt.Sprintf(`, ATTRS{idProduct}=="%s"`, device.Product)
+			}
+		default:
+			// Subsystem entries only narrow DeviceAllow; access to the nodes
+			// comes from groups
+			continue
+		}
+
+		rules = append(rules, match+", "+owner)
+	}
+
+	return rules
+}
+
+// UdevTriggers returns udevadm trigger filters that re-apply the rules to
+// devices that are already plugged in.
+func (cfg *ServiceConfig) UdevTriggers() []string {
+	var (
+		subsystems []string
+		triggers   []string
+	)
+
+	for _, device := range cfg.AllowedDevices {
+		switch {
+		case device.Path != "":
+			triggers = append(triggers, "--name-match="+device.Path)
+		case device.Vendor != "":
+			subsystems = append(subsystems, "--subsystem-match="+device.Subsystem)
+		}
+	}
+
+	if len(subsystems) > 0 {
+		triggers = append([]string{strings.Join(uniqueStrings(subsystems), " ")}, triggers...)
+	}
+
+	return triggers
+}
+
+func uniqueStrings(values []string) []string {
+	var unique []string
+
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
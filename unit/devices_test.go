@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceValidate(t *testing.T) {
+	invalid := []Device{
+		{},
+		{Path: "/dev/ttyUSB0", Subsystem: "tty"},
+		{Path: "/etc/passwd"},
+		{Path: "/dev"},
+		{Path: "/dev/ttyUSB0", Vendor: "0403"},
+		{Path: "/dev/mem"},
+		{Path: "/dev/kmem"},
+		{Path: "/dev/port"},
+		{Path: "/dev/sda"},
+		{Path: "/dev/nvme0n1"},
+		{Path: "/dev/disk/by-id/usb-Example-0:0"},
+		{Path: "/dev/serial/../sda"},
+		{Subsystem: "block"},
+		{Subsystem: "tty", Vendor: "0403", Product: "60ZZ"},
+		{Subsystem: "tty", Vendor: "04O3"},
+		{Subsystem: "usb", Product: "6001"},
+	}
+
+	for _, device := range invalid {
+		if device.Validate() == nil {
+			t.Errorf("expected %+v to be rejected", device)
+		}
+	}
+
+	valid := []Device{
+		{Path: "/dev/ttyUSB0"},
+		{Path: "/dev/serial/by-id/usb-Example-if00"},
+		{Path: "/dev/snd/pcmC0D0p"},
+		{Path: "/dev/bus/usb/001/004"},
+		{Subsystem: "hidraw"},
+		{Subsystem: "tty", Vendor: "0403", Product: "6001"},
+	}
+
+	for _, device := range valid {
+		if err := device.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid: %v", device, err)
+		}
+	}
+}
+
+func TestAllowedDevicesFullDevices(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Devices = true
+	cfg.AllowedDevices = []Device{{Path: "/dev/ttyUSB0"}}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected allowed devices to be valid: %v", err)
+	}
+
+	cfg.FullDevices = true
+
+	if cfg.Validate() == nil {
+		t.Error("expected allowed_devices with full_devices to be rejected")
+	}
+}
+
+func TestAllowedDevicesRerun(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Devices = true
+	cfg.AllowedDevices = []Device{{Subsystem: "tty", Vendor: "0403"}}
+
+	servicePath := filepath.Join(t.TempDir(), "example.service")
+
+	for run := range 2 {
+		if _, err := cfg.Prepare(servicePath); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+
+		files, err := Render(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(servicePath, files["example.service"], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(cfg.Custom) != 0 {
+		t.Errorf("expected DeviceAllow to stay managed, got custom %v", cfg.Custom)
+	}
+
+	cfg.AllowedDevices = nil
+
+	files, err := Render(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := files["99-example.rules"]; ok {
+		t.Error("expected no udev rules without allowed_devices")
+	}
+}
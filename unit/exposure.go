@@ -0,0 +1,65 @@
+package unit
+
+// Exposure is one relaxation of the default sandbox and how much it adds to
+// the exposure score.
+type Exposure struct {
+	Setting string
+	Weight  float64
+}
+
+// Exposures lists what the configuration opens up, heaviest first within
+// each area. The weights add up to 10 with every option enabled, similar to
+// the scale of systemd-analyze security.
+func (cfg *ServiceConfig) Exposures() []Exposure {
+	var exposures []Exposure
+
+	add := func(enabled bool, setting string, weight float64) {
+		if enabled {
+			exposures = append(exposures, Exposure{setting, weight})
+		}
+	}
+
+	add(cfg.Network && !cfg.LocalhostOnly, "network", 1.5)
+	add(cfg.Network && cfg.LocalhostOnly, "network (localhost only)", 0.5)
+	add(cfg.Listening, "listening", 0.5)
+	add(cfg.PrivilegedPorts, "privileged_ports", 1)
+
+	add(cfg.WritableFiles, "writable_files", 0.5)
+	add(cfg.WritableConfig, "writable_config", 0.5)
+
+	add(cfg.FullDevices, "full_devices", 2.5)
+	add(cfg.Devices && !cfg.FullDevices, "devices", 1)
+
+	add(cfg.ExecMemory, "exec_memory", 1)
+	add(cfg.Subprocess, "subprocess", 1)
+	add(!cfg.PrivateUsers, "no private_users", 0.5)
+	add(len(cfg.Groups) > 0, "groups", 0.5)
+
+	add(cfg.CPUQuota == "", "no cpu_quota", 0.25)
+	add(cfg.MemoryMax == "", "no memory_max", 0.25)
+
+	return exposures
+}
+
+// ExposureScore sums the exposures, from 0 (fully sandboxed) to 10.
+func (cfg *ServiceConfig) ExposureScore() float64 {
+	var score float64
+
+	for _, exposure := range cfg.Exposures() {
+		score += exposure.Weight
+	}
+
+	return min(score, 10)
+}
+
+// ExposureRating names the band of an exposure score.
+func ExposureRating(score float64) string {
+	switch {
+	case score < 3:
+		return "LOW"
+	case score < 6:
+		return "MEDIUM"
+	default:
+		return "HIGH"
+	}
+}
@@ -0,0 +1,40 @@
+package unit
+
+import "testing"
+
+func TestExposureScore(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.PrivateUsers = true
+	cfg.CPUQuota = "100%"
+	cfg.MemoryMax = "1G"
+
+	if score := cfg.ExposureScore(); score != 0 {
+		t.Errorf("expected a fully sandboxed service to score 0, got %v (%v)", score, cfg.Exposures())
+	}
+
+	cfg = NewServiceConfig("example", "/opt/example")
+	cfg.Network = true
+	cfg.Listening = true
+	cfg.PrivilegedPorts = true
+	cfg.WritableFiles = true
+	cfg.WritableConfig = true
+	cfg.Devices = true
+	cfg.FullDevices = true
+	cfg.ExecMemory = true
+	cfg.Subprocess = true
+	cfg.Groups = []string{"media"}
+
+	if score := cfg.ExposureScore(); score != 10 {
+		t.Errorf("expected every relaxation to score 10, got %v", score)
+	}
+
+	if rating := ExposureRating(cfg.ExposureScore()); rating != "HIGH" {
+		t.Errorf("expected HIGH, got %s", rating)
+	}
+
+	cfg.LocalhostOnly = true
+
+	if score := cfg.ExposureScore(); score != 9 {
+		t.Errorf("expected localhost_only to lower the score to 9, got %v", score)
+	}
+}
@@ -0,0 +1,328 @@
+package unit
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+type goldenCase struct {
+	name    string
+	setup   func(cfg *ServiceConfig)
+	service string
+}
+
+var goldenCases = []goldenCase{
+	{
+		name: "default",
+	},
+	{
+		name: "outbound",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+		},
+	},
+	{
+		name: "server",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.Listening = true
+		},
+	},
+	{
+		name: "privileged-ports",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.Listening = true
+			cfg.PrivilegedPorts = true
+			cfg.PrivateUsers = true
+		},
+	},
+	{
+		name: "localhost-private-users",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.LocalhostOnly = true
+			cfg.PrivateUsers = true
+		},
+	},
+	{
+		name: "exec-memory-subprocess",
+		setup: func(cfg *ServiceConfig) {
+			cfg.ExecMemory = true
+			cfg.Subprocess = true
+		},
+	},
+	{
+		name: "writable",
+		setup: func(cfg *ServiceConfig) {
+			cfg.WritableFiles = true
+			cfg.WritableConfig = true
+			cfg.ConfigFile = "config.yml"
+			cfg.RuntimeDir = true
+		},
+	},
+	{
+		name: "groups",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.Groups = []string{"ssl-cert", "media"}
+		},
+	},
+	{
+		name: "no-log-dir",
+		setup: func(cfg *ServiceConfig) {
+			cfg.SeparateLogDir = false
+		},
+	},
+	{
+		name: "devices",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Devices = true
+		},
+	},
+	{
+		name: "allowed-devices",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Devices = true
+			cfg.AllowedDevices = []Device{
+				{Subsystem: "tty", Vendor: "0403", Product: "6001"},
+				{Path: "/dev/serial/by-id/usb-Example_Sensor-if00"},
+				{Subsystem: "gpio"},
+			}
+		},
+	},
+	{
+		name: "full-devices",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Devices = true
+			cfg.FullDevices = true
+			cfg.Network = true
+		},
+	},
+	{
+		name: "restart",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Restart = &RestartPolicy{
+				Mode:              "always",
+				Delay:             1,
+				Steps:             5,
+				MaxDelay:          30,
+				Burst:             5,
+				Interval:          300,
+				SuccessExitStatus: []string{"143", "SIGTERM"},
+				PreventExitStatus: []string{"2"},
+				OnFailure:         []string{"alert@example.service"},
+			}
+		},
+	},
+	{
+		name: "notify",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.NotifyOnFailure = &FailureNotify{
+				Webhook: "https://hooks.example.com/services/T000/B000",
+			}
+		},
+	},
+	{
+		name: "dependencies",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.DependsOn = []string{"queue", "postgresql.service"}
+			cfg.Wants = []string{"redis.socket"}
+			cfg.BindsTo = []string{"example-db.mount"}
+			cfg.Before = []string{"example-worker"}
+		},
+	},
+	{
+		name: "limits-env",
+		setup: func(cfg *ServiceConfig) {
+			cfg.CPUQuota = "150%"
+			cfg.MemoryMax = "512M"
+			cfg.EnvFile = "/opt/example/.env"
+		},
+	},
+	{
+		name: "normalized",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Listening = true
+			cfg.PrivilegedPorts = true
+			cfg.LocalhostOnly = true
+			cfg.FullDevices = true
+		},
+	},
+	{
+		name: "health",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.Listening = true
+			cfg.Health = &HealthCheck{
+				HTTP: "http://127.0.0.1:8080/health",
+			}
+		},
+	},
+	{
+		name: "openrc",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Backend = "openrc"
+		},
+	},
+	{
+		name: "openrc-server",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Backend = "openrc"
+			cfg.Network = true
+			cfg.Listening = true
+			cfg.PrivilegedPorts = true
+			cfg.WritableFiles = true
+			cfg.RuntimeDir = true
+			cfg.CPUQuota = "150%"
+			cfg.MemoryMax = "1.5G"
+			cfg.Groups = []string{"ssl-cert"}
+			cfg.DependsOn = []string{"postgresql"}
+			cfg.Wants = []string{"redis"}
+			cfg.Health = &HealthCheck{
+				Command: "/opt/example/example --check",
+			}
+		},
+	},
+	{
+		name: "quadlet",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Backend = "quadlet"
+			cfg.Image = "ghcr.io/example/example:1.2.3"
+		},
+	},
+	{
+		name: "quadlet-server",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Backend = "quadlet"
+			cfg.Image = "ghcr.io/example/example:1.2.3"
+			cfg.Network = true
+			cfg.Listening = true
+			cfg.PrivilegedPorts = true
+			cfg.LocalhostOnly = true
+			cfg.WritableFiles = true
+			cfg.RuntimeDir = true
+			cfg.ExecMemory = true
+			cfg.CPUQuota = "50%"
+			cfg.MemoryMax = "512M"
+			cfg.EnvFile = "/opt/example/.env"
+		},
+	},
+	{
+		name: "user-mode",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Backend = "systemd-user"
+			cfg.Path = "/home/dev/example"
+			cfg.AllowedRoots = []string{"/home/dev"}
+			cfg.Network = true
+			cfg.LocalhostOnly = true
+			cfg.WritableFiles = true
+			cfg.Health = &HealthCheck{
+				Command: "/home/dev/example/example --check",
+			}
+		},
+	},
+	{
+		name: "preserved",
+		setup: func(cfg *ServiceConfig) {
+			cfg.Network = true
+		},
+		service: `[Unit]
+Description=Example
+After=network.target postgresql.service
+Requires=network.target postgresql.service
+
+[Service]
+User=example
+Environment=API_KEY=12345
+Environment="GREETING=hello world"
+TimeoutStartSec=600
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`,
+	},
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewServiceConfig("example", "/opt/example")
+
+			if tc.setup != nil {
+				tc.setup(cfg)
+			}
+
+			servicePath := filepath.Join(t.TempDir(), "example.service")
+
+			if tc.service != "" {
+				err := os.WriteFile(servicePath, []byte(tc.service), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := cfg.Prepare(servicePath)
+			if err != nil {
+				t.Fatalf("prepare: %v", err)
+			}
+
+			files, err := Render(cfg)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+
+			dir := filepath.Join("testdata", "golden", tc.name)
+
+			if *update {
+				err = os.RemoveAll(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = os.MkdirAll(dir, 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for name, got := range files {
+				path := filepath.Join(dir, name)
+
+				if *update {
+					err = os.WriteFile(path, got, 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					continue
+				}
+
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("%v (run go test ./unit -update to create it)", err)
+				}
+
+				if string(got) != string(want) {
+					t.Errorf("%s differs from golden file %s (run go test ./unit -update after reviewing)\n--- got ---\n%s", name, path, got)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(files) {
+				t.Errorf("golden directory %s has %d files, rendered %d", dir, len(entries), len(files))
+			}
+		})
+	}
+}
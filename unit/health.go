@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+)
+
+var (
+	healthURLRgx     = regexp.MustCompile(`^https?://[A-Za-z0-9.-]+(?::[0-9]{1,5})?(?:/[A-Za-z0-9._~/?&=%+:@,-]*)?$`)
+	healthAddressRgx = regexp.MustCompile(`^[A-Za-z0-9.-]+:[0-9]{1,5}$`)
+
+	// Commands are embedded in single quotes in setup.sh
+	healthCommandRgx = regexp.MustCompile(`^[^'\x00-\x1f\x7f]{1,512}$`)
+)
+
+const defaultHealthTimeout = 30
+
+// HealthCheck is run by setup.sh after (re)starting the service. Exactly one
+// of HTTP, TCP, Unix and Command is set.
+type HealthCheck struct {
+	HTTP    string `yaml:"http,omitempty" json:"http,omitempty"`
+	TCP     string `yaml:"tcp,omitempty" json:"tcp,omitempty"`
+	Unix    string `yaml:"unix,omitempty" json:"unix,omitempty"`
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	Timeout int    `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+func (h *HealthCheck) Validate() error {
+	var kinds int
+
+	for _, value := range []string{h.HTTP, h.TCP, h.Unix, h.Command} {
+		if value != "" {
+			kinds++
+		}
+	}
+
+	if kinds != 1 {
+		return fmt.Errorf("health check needs exactly one of http, tcp, unix or command")
+	}
+
+	if h.HTTP != "" && !healthURLRgx.MatchString(h.HTTP) {
+		return fmt.Errorf("invalid health check URL %q", h.HTTP)
+	}
+
+	if h.TCP != "" {
+		_, port, err := net.SplitHostPort(h.TCP)
+		if err != nil || !healthAddressRgx.MatchString(h.TCP) {
+			return fmt.Errorf("invalid health check address %q: must be host:port", h.TCP)
+		}
+
+		if number, _ := strconv.Atoi(port); number < 1 || number > 65535 {
+			return fmt.Errorf("invalid health check port %q", port)
+		}
+	}
+
+	if h.Unix != "" && !validAbsolutePath(h.Unix) {
+		return fmt.Errorf("invalid health check socket %q", h.Unix)
+	}
+
+	if h.Command != "" && !healthCommandRgx.MatchString(h.Command) {
+		return fmt.Errorf("invalid health check command %q: must be a single line without single quotes", h.Command)
+	}
+
+	if h.Timeout < 1 || h.Timeout > 600 {
+		return fmt.Errorf("invalid health check timeout %d: must be between 1 and 600 seconds", h.Timeout)
+	}
+
+	return nil
+}
+
+func (h *HealthCheck) String() string {
+	switch {
+	case h.HTTP != "":
+		return "http " + h.HTTP
+	case h.TCP != "":
+		return "tcp " + h.TCP
+	case h.Unix != "":
+		return "unix " + h.Unix
+	default:
+		return "command " + h.Command
+	}
+}
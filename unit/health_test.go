@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHealthCheckScript(t *testing.T) {
+	for _, tool := range []string{"bash", "curl", "timeout"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(healthy.Close)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closedAddr := closed.Addr().String()
+
+	closed.Close()
+
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "app.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	// A socket file left behind by a process that is gone
+	staleSocket := filepath.Join(dir, "stale.sock")
+
+	stale, err := net.Listen("unix", staleSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	// Stand-ins for systemctl and runuser so the check runs unprivileged
+	bin := filepath.Join(dir, "bin")
+
+	stubs := map[string]string{
+		"systemctl": "#!/bin/sh\nexit 0\n",
+		"runuser":   "#!/bin/sh\nshift 3\nexec \"$@\"\n",
+	}
+
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, script := range stubs {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		check   HealthCheck
+		healthy bool
+	}{
+		{"http", HealthCheck{HTTP: healthy.URL + "/health"}, true},
+		{"http-failing", HealthCheck{HTTP: failing.URL + "/health"}, false},
+		{"tcp", HealthCheck{TCP: strings.TrimPrefix(healthy.URL, "http://")}, true},
+		{"tcp-closed", HealthCheck{TCP: closedAddr}, false},
+		{"unix", HealthCheck{Unix: socket}, true},
+		{"unix-stale", HealthCheck{Unix: staleSocket}, false},
+		{"unix-missing", HealthCheck{Unix: filepath.Join(dir, "missing.sock")}, false},
+		{"command", HealthCheck{Command: "test -d /"}, true},
+		{"command-failing", HealthCheck{Command: "exit 3"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.check.Unix != "" {
+				if _, err := exec.LookPath("socat"); err != nil {
+					t.Skip("socat not available")
+				}
+			}
+
+			tc.check.Timeout = 1
+
+			if err := tc.check.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := NewServiceConfig("example", "/opt/example")
+			cfg.Health = &tc.check
+
+			var script bytes.Buffer
+
+			script.WriteString("set -euo pipefail\nname=example\n")
+
+			if err := SetupTmpl.ExecuteTemplate(&script, "health", cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			script.WriteString("\nwait_healthy\n")
+
+			cmd := exec.Command("bash", "-c", script.String())
+			cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+
+			out, err := cmd.CombinedOutput()
+
+			if tc.healthy && err != nil {
+				t.Fatalf("expected healthy, got %v\n%s\n%s", err, out, script.String())
+			}
+
+			if !tc.healthy && err == nil {
+				t.Fatalf("expected failure\n%s", script.String())
+			}
+		})
+	}
+}
+
+func TestHealthCheckValidate(t *testing.T) {
+	cases := map[string]HealthCheck{
+		"none":          {Timeout: 30},
+		"two kinds":     {HTTP: "http://localhost/", TCP: "localhost:80", Timeout: 30},
+		"url quote":     {HTTP: "http://localhost/'$(id)'", Timeout: 30},
+		"url scheme":    {HTTP: "file:///etc/passwd", Timeout: 30},
+		"tcp port":      {TCP: "localhost", Timeout: 30},
+		"tcp range":     {TCP: "localhost:70000", Timeout: 30},
+		"unix relative": {Unix: "run/app.sock", Timeout: 30},
+		"command quote": {Command: "echo 'hi'", Timeout: 30},
+		"command line":  {Command: "true\nreboot", Timeout: 30},
+		"timeout":       {TCP: "localhost:80", Timeout: 601},
+	}
+
+	for name, check := range cases {
+		if err := check.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package unit
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNote    = "note"
+)
+
+// Strictness of enumerated directives, higher is stricter. A missing
+// directive falls back to the systemd default, which is always the lowest.
+var lintLevels = map[string]map[string]int{
+	"ProtectSystem": {"": 0, "no": 0, "false": 0, "yes": 1, "true": 1, "full": 2, "strict": 3},
+	"ProtectHome":   {"": 0, "no": 0, "false": 0, "tmpfs": 1, "read-only": 2, "yes": 3, "true": 3},
+	"DevicePolicy":  {"": 0, "auto": 0, "closed": 1, "strict": 2},
+	"ProtectProc":   {"": 0, "default": 0, "ptraceable": 1, "invisible": 2, "noaccess": 3},
+	"ProcSubset":    {"": 0, "all": 0, "pid": 1},
+	"KeyringMode":   {"": 0, "inherit": 0, "shared": 1, "private": 2},
+}
+
+var (
+	// Directives listing what is allowed; additions widen the sandbox. The
+	// value tells whether removing the directive allows everything.
+	lintAllowLists = map[string]bool{
+		"CapabilityBoundingSet":   true,
+		"RestrictAddressFamilies": true,
+		"SystemCallArchitectures": true,
+		"AmbientCapabilities":     false,
+		"ReadWritePaths":          false,
+		"IPAddressAllow":          false,
+		"DeviceAllow":             false,
+		"SupplementaryGroups":     false,
+	}
+
+	// Directives listing what is denied; removals widen the sandbox
+	lintDenyLists = map[string]bool{
+		"SystemCallFilter":  true,
+		"InaccessiblePaths": true,
+		"ReadOnlyPaths":     true,
+		"IPAddressDeny":     true,
+		"SocketBindDeny":    true,
+	}
+
+	// Directives naming who the service runs as; any change is an error
+	lintIdentities = map[string]bool{
+		"User":  true,
+		"Group": true,
+	}
+)
+
+type LintFinding struct {
+	Severity string
+	Section  string
+	Key      string
+	Line     int
+	Message  string
+}
+
+// Lint compares every managed directive of current with the unit cfg renders
+// and reports changes that weaken the sandbox as errors. Unmanaged directives
+// that the next run would refuse to preserve are reported as warnings. cfg
+// should be prepared first.
+func (cfg *ServiceConfig) Lint(current *UnitFile) ([]LintFinding, error) {
+	data, err := cfg.RenderArtifact("service")
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := ParseUnitFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := expected.Keys("Service")
+
+	for _, key := range current.Keys("Service") {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	defaults := defaultLimits()
+
+	var findings []LintFinding
+
+	for _, key := range keys {
+		finding := LintFinding{
+			Section: "Service",
+			Key:     key,
+			Line:    directiveLine(current, "Service", key),
+		}
+
+		if !managedKeys[key] {
+			if _, isDefault := defaults[key]; isDefault || preservedCustomKeys[key] || !hasDirective(current, "Service", key) {
+				continue
+			}
+
+			finding.Severity = SeverityWarning
+			finding.Message = fmt.Sprintf("%s is not supported and will be rejected on the next run", key)
+
+			findings = append(findings, finding)
+
+			continue
+		}
+
+		want := expected.Values("Service", key)
+		got := current.Values("Service", key)
+
+		wantSet := hasDirective(expected, "Service", key)
+		gotSet := hasDirective(current, "Service", key)
+
+		if wantSet == gotSet && slices.Equal(want, got) {
+			continue
+		}
+
+		finding.Severity, finding.Message = lintDirective(key, want, got, gotSet)
+		if finding.Severity == "" {
+			continue
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+func lintDirective(key string, want, got []string, gotSet bool) (string, string) {
+	if levels, ok := lintLevels[key]; ok {
+		return lintLevel(key, levels, lastValue(want), lastValue(got))
+	}
+
+	if lintIdentities[key] {
+		return lintIdentity(key, lastValue(want), lastValue(got))
+	}
+
+	if key == "UMask" {
+		return lintUMask(lastValue(want), lastValue(got))
+	}
+
+	if _, ok := lintAllowLists[key]; ok {
+		return lintAllowList(key, want, got, gotSet)
+	}
+
+	if lintDenyLists[key] {
+		return lintDenyList(key, want, got)
+	}
+
+	if wantBool, ok := parseBool(lastValue(want)); ok {
+		gotBool, _ := parseBool(lastValue(got))
+
+		if wantBool && !gotBool {
+			return SeverityError, fmt.Sprintf("%s disabled (generated: %s)", key, lastValue(want))
+		}
+
+		if !wantBool && gotBool {
+			return SeverityNote, fmt.Sprintf("%s enabled, which is stricter than generated", key)
+		}
+	}
+
+	if !gotSet {
+		return SeverityWarning, fmt.Sprintf("%s removed (generated: %s)", key, strings.Join(want, " "))
+	}
+
+	if len(want) == 0 {
+		return SeverityWarning, fmt.Sprintf("%s set to %s but is not generated for this configuration", key, strings.Join(got, " "))
+	}
+
+	return SeverityWarning, fmt.Sprintf("%s changed from %s to %s", key, strings.Join(want, " "), strings.Join(got, " "))
+}
+
+func lintLevel(key string, levels map[string]int, want, got string) (string, string) {
+	gotLevel, ok := levels[got]
+	if !ok {
+		return SeverityWarning, fmt.Sprintf("%s has unrecognized value %q (generated: %s)", key, got, want)
+	}
+
+	if gotLevel < levels[want] {
+		return SeverityError, fmt.Sprintf("%s lowered from %s to %s", key, want, valueOrDefault(got))
+	}
+
+	if gotLevel == levels[want] {
+		return "", ""
+	}
+
+	return SeverityNote, fmt.Sprintf("%s raised from %s to %s", key, want, got)
+}
+
+func lintIdentity(key, want, got string) (string, string) {
+	if got == "" {
+		if key == "User" {
+			return SeverityError, fmt.Sprintf("%s removed, which runs the service as root", key)
+		}
+
+		return SeverityError, fmt.Sprintf("%s removed (generated: %s)", key, want)
+	}
+
+	return SeverityError, fmt.Sprintf("%s changed from %s to %s", key, valueOrDefault(want), got)
+}
+
+// lintUMask compares the masked bits, a missing UMask falls back to the
+// systemd default of 0022.
+func lintUMask(want, got string) (string, string) {
+	if got == "" {
+		got = "0022"
+	}
+
+	wantMask, err := strconv.ParseUint(want, 8, 32)
+	if err != nil {
+		return SeverityWarning, fmt.Sprintf("UMask has unrecognized generated value %q", want)
+	}
+
+	gotMask, err := strconv.ParseUint(got, 8, 32)
+	if err != nil {
+		return SeverityWarning, fmt.Sprintf("UMask has unrecognized value %q (generated: %s)", got, want)
+	}
+
+	if wantMask&^gotMask != 0 {
+		return SeverityError, fmt.Sprintf("UMask loosened from %s to %s", want, got)
+	}
+
+	if gotMask&^wantMask != 0 {
+		return SeverityNote, fmt.Sprintf("UMask tightened from %s to %s", want, got)
+	}
+
+	return "", ""
+}
+
+func lintAllowList(key string, want, got []string, gotSet bool) (string, string) {
+	if !gotSet {
+		if lintAllowLists[key] {
+			return SeverityError, fmt.Sprintf("%s removed, which allows everything", key)
+		}
+
+		return SeverityNote, fmt.Sprintf("%s removed, which is stricter than generated", key)
+	}
+
+	wantItems, _ := allowedItems(key, want)
+
+	gotItems, inverted := allowedItems(key, got)
+	if inverted {
+		return SeverityError, fmt.Sprintf("%s inverted to a deny list, which widens it", key)
+	}
+
+	if added := difference(gotItems, wantItems); len(added) > 0 {
+		return SeverityError, fmt.Sprintf("%s widened: adds %s", key, strings.Join(added, " "))
+	}
+
+	if dropped := difference(wantItems, gotItems); len(dropped) > 0 {
+		return SeverityNote, fmt.Sprintf("%s narrowed: drops %s", key, strings.Join(dropped, " "))
+	}
+
+	return "", ""
+}
+
+func lintDenyList(key string, want, got []string) (string, string) {
+	if len(got) == 0 {
+		return SeverityError, fmt.Sprintf("%s removed", key)
+	}
+
+	wantItems, _ := listSet(want)
+
+	gotItems, inverted := listSet(got)
+	if key == "SystemCallFilter" && !inverted {
+		return SeverityWarning, fmt.Sprintf("%s changed to an allow list; review it manually", key)
+	}
+
+	if removed := difference(wantItems, gotItems); len(removed) > 0 {
+		return SeverityError, fmt.Sprintf("%s entries removed: %s", key, strings.Join(removed, " "))
+	}
+
+	if added := difference(gotItems, wantItems); len(added) > 0 {
+		return SeverityNote, fmt.Sprintf("%s extended: adds %s", key, strings.Join(added, " "))
+	}
+
+	return "", ""
+}
+
+func allowedItems(key string, values []string) ([]string, bool) {
+	if key == "DeviceAllow" {
+		return deviceAccess(values), false
+	}
+
+	return listSet(values)
+}
+
+// deviceAccess splits every DeviceAllow assignment into one item per device
+// and access character, so granting more access to a listed device counts as
+// an addition. Without explicit access systemd grants rwm.
+func deviceAccess(values []string) []string {
+	var items []string
+
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		access := "rwm"
+		if len(fields) > 1 {
+			access = fields[1]
+		}
+
+		for _, char := range access {
+			item := fields[0] + ":" + string(char)
+
+			if !slices.Contains(items, item) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// listSet evaluates the assignments of a list directive in order, the way
+// systemd does: the first one decides whether the list is inverted (a leading
+// ~), later ones of the same kind add to it and ones of the other kind take
+// their items back out. Values already applies empty resets.
+func listSet(values []string) ([]string, bool) {
+	var (
+		items    []string
+		inverted bool
+	)
+
+	for i, value := range values {
+		fields := strings.Fields(value)
+
+		// A leading ~ inverts the whole assignment, not the first item
+		negated := len(fields) > 0 && len(fields[0]) > 1 && strings.HasPrefix(fields[0], "~")
+		if negated {
+			fields[0] = strings.TrimPrefix(fields[0], "~")
+		}
+
+		if i == 0 {
+			inverted = negated
+		}
+
+		for _, item := range fields {
+			if negated == inverted {
+				if !slices.Contains(items, item) {
+					items = append(items, item)
+				}
+
+				continue
+			}
+
+			items = slices.DeleteFunc(items, func(existing string) bool {
+				return existing == item
+			})
+		}
+	}
+
+	return items, inverted
+}
+
+func difference(a, b []string) []string {
+	var diff []string
+
+	for _, item := range a {
+		if !slices.Contains(b, item) && !slices.Contains(diff, item) {
+			diff = append(diff, item)
+		}
+	}
+
+	return diff
+}
+
+func hasDirective(file *UnitFile, section, key string) bool {
+	for _, entry := range file.Entries(section) {
+		if entry.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func directiveLine(file *UnitFile, section, key string) int {
+	var line int
+
+	for _, s := range file.Sections {
+		if s.Name != section {
+			continue
+		}
+
+		if line == 0 {
+			line = s.Line
+		}
+
+		for _, entry := range s.Entries {
+			if entry.Key == key {
+				line = entry.Line
+			}
+		}
+	}
+
+	return line
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
+
+func valueOrDefault(value string) string {
+	if value == "" {
+		return "the systemd default"
+	}
+
+	return value
+}
+
+func parseBool(value string) (bool, bool) {
+	switch value {
+	case "yes", "true", "on", "1":
+		return true, true
+	case "no", "false", "off", "0":
+		return false, true
+	}
+
+	return false, false
+}
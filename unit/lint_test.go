@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name     string
+		edit     func(unit string) string
+		severity string
+		message  string
+	}{
+		{"unchanged", func(u string) string { return u }, "", ""},
+		{"protect-system", replaceLine("ProtectSystem=strict", "ProtectSystem=full"), SeverityError, "ProtectSystem lowered from strict to full"},
+		{"protect-system-removed", replaceLine("ProtectSystem=strict", ""), SeverityError, "lowered from strict to the systemd default"},
+		{"capabilities", replaceLine("CapabilityBoundingSet=", "CapabilityBoundingSet=CAP_SYS_ADMIN"), SeverityError, "widened: adds CAP_SYS_ADMIN"},
+		{"capabilities-removed", replaceLine("CapabilityBoundingSet=", ""), SeverityError, "allows everything"},
+		{"syscall-filter", func(u string) string { return strings.Replace(u, " @mount", "", 1) }, SeverityError, "entries removed: @mount"},
+		{"syscall-filter-extended", func(u string) string { return strings.Replace(u, "@memlock", "@memlock @timer", 1) }, SeverityNote, "adds @timer"},
+		{"syscall-filter-allowed-again", func(u string) string {
+			return strings.Replace(u, "@memlock\n", "@memlock\nSystemCallFilter=@privileged\n", 1)
+		}, SeverityError, "entries removed: @privileged"},
+		{"syscall-filter-reset", func(u string) string {
+			return strings.Replace(u, "@memlock\n", "@memlock\nSystemCallFilter=\nSystemCallFilter=~@mount\n", 1)
+		}, SeverityError, "entries removed: @clock"},
+		{"syscall-filter-denied-again", func(u string) string {
+			return strings.Replace(u, "@memlock\n", "@memlock\nSystemCallFilter=~@timer\n", 1)
+		}, SeverityNote, "adds @timer"},
+		{"boolean", replaceLine("NoNewPrivileges=yes", "NoNewPrivileges=no"), SeverityError, "NoNewPrivileges disabled"},
+		{"address-families", replaceLine("RestrictAddressFamilies=AF_UNIX", "RestrictAddressFamilies=AF_UNIX AF_PACKET"), SeverityError, "adds AF_PACKET"},
+		{"address-families-denied", replaceLine("RestrictAddressFamilies=AF_UNIX", "RestrictAddressFamilies=AF_UNIX\nRestrictAddressFamilies=~AF_UNIX"), SeverityNote, "drops AF_UNIX"},
+		{"address-families-inverted", replaceLine("RestrictAddressFamilies=AF_UNIX", "RestrictAddressFamilies=~AF_PACKET"), SeverityError, "inverted to a deny list"},
+		{"address-families-reset", replaceLine("RestrictAddressFamilies=AF_UNIX", "RestrictAddressFamilies=~AF_UNIX\nRestrictAddressFamilies=\nRestrictAddressFamilies=AF_UNIX AF_INET"), SeverityError, "adds AF_INET"},
+		{"writable-paths", func(u string) string {
+			return strings.Replace(u, "ReadWritePaths=/opt/example/logs", "ReadWritePaths=/opt/example/logs /etc", 1)
+		}, SeverityError, "adds /etc"},
+		{"user-root", replaceLine("User=example", "User=root"), SeverityError, "User changed from example to root"},
+		{"user-removed", replaceLine("User=example", ""), SeverityError, "runs the service as root"},
+		{"group", replaceLine("Group=example", "Group=wheel"), SeverityError, "Group changed from example to wheel"},
+		{"supplementary-groups", replaceLine("Group=example", "Group=example\nSupplementaryGroups=disk"), SeverityError, "SupplementaryGroups widened: adds disk"},
+		{"device-allow", replaceLine("DevicePolicy=closed", "DevicePolicy=closed\nDeviceAllow=/dev/sda rw"), SeverityError, "adds /dev/sda:r /dev/sda:w"},
+		{"device-allow-default-access", replaceLine("DevicePolicy=closed", "DevicePolicy=closed\nDeviceAllow=/dev/sda r\nDeviceAllow=/dev/sda"), SeverityError, "adds /dev/sda:r /dev/sda:w /dev/sda:m"},
+		{"umask", replaceLine("UMask=0077", "UMask=0000"), SeverityError, "UMask loosened from 0077 to 0000"},
+		{"umask-removed", replaceLine("UMask=0077", ""), SeverityError, "UMask loosened from 0077 to 0022"},
+		{"umask-tightened", replaceLine("UMask=0077", "UMask=0277"), SeverityNote, "UMask tightened"},
+		{"architectures-removed", replaceLine("SystemCallArchitectures=native", ""), SeverityError, "SystemCallArchitectures removed, which allows everything"},
+		{"architectures-widened", replaceLine("SystemCallArchitectures=native", "SystemCallArchitectures=native x86"), SeverityError, "adds x86"},
+		{"exec-start", replaceLine("ExecStart=/opt/example/example", "ExecStart=/opt/example/other"), SeverityWarning, "ExecStart changed"},
+		{"unsupported", replaceLine("UMask=0077", "UMask=0077\nExecStartPre=/bin/true"), SeverityWarning, "ExecStartPre is not supported"},
+		{"continued", replaceLine("PrivateTmp=yes", "PrivateTmp=\\\n  no"), SeverityError, "PrivateTmp disabled"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewServiceConfig("example", "/opt/example")
+
+			if _, err := cfg.Prepare(""); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := cfg.RenderArtifact("service")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			current, err := ParseUnitFile([]byte(tc.edit(string(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := cfg.Lint(current)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.severity == "" {
+				if len(findings) != 0 {
+					t.Fatalf("unexpected findings: %+v", findings)
+				}
+
+				return
+			}
+
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, want 1: %+v", len(findings), findings)
+			}
+
+			if findings[0].Severity != tc.severity || !strings.Contains(findings[0].Message, tc.message) {
+				t.Errorf("got %s %q, want %s containing %q", findings[0].Severity, findings[0].Message, tc.severity, tc.message)
+			}
+
+			if findings[0].Line == 0 {
+				t.Error("finding has no line number")
+			}
+		})
+	}
+}
+
+func replaceLine(old, replacement string) func(string) string {
+	return func(unit string) string {
+		lines := strings.Split(unit, "\n")
+
+		for i, line := range lines {
+			if line == old {
+				lines[i] = replacement
+			}
+		}
+
+		return strings.Join(lines, "\n")
+	}
+}
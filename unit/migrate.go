@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+const ConfigVersion = 2
+
+// Each migration upgrades a raw svc.yml document from version index+1 to
+// index+2 and returns a description of every change it made.
+var migrations = []func(raw map[string]any) []string{
+	migrateConfigFile,
+}
+
+func migrateConfig(data []byte) ([]byte, []string, error) {
+	var raw map[string]any
+
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	if raw == nil {
+		raw = make(map[string]any)
+	}
+
+	version := 1
+
+	if value, ok := raw["version"]; ok {
+		switch v := value.(type) {
+		case uint64:
+			version = int(v)
+		case int64:
+			version = int(v)
+		default:
+			return nil, nil, fmt.Errorf("invalid config version %v", value)
+		}
+	}
+
+	if version < 1 {
+		return nil, nil, fmt.Errorf("invalid config version %d", version)
+	}
+
+	if version > ConfigVersion {
+		return nil, nil, fmt.Errorf("config version %d is newer than supported version %d, upgrade mksvc", version, ConfigVersion)
+	}
+
+	if version == ConfigVersion {
+		return data, nil, nil
+	}
+
+	var changes []string
+
+	for ; version < ConfigVersion; version++ {
+		changes = append(changes, migrations[version-1](raw)...)
+		changes = append(changes, fmt.Sprintf("upgraded from version %d to %d", version, version+1))
+	}
+
+	raw["version"] = ConfigVersion
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, changes, nil
+}
+
+// Before config_file existed, writable_config always referred to config.yml
+// next to the executable.
+func migrateConfigFile(raw map[string]any) []string {
+	writable, _ := raw["writable_config"].(bool)
+	if !writable {
+		return nil
+	}
+
+	if file, _ := raw["config_file"].(string); file != "" {
+		return nil
+	}
+
+	raw["config_file"] = "config.yml"
+
+	return []string{"set config_file to config.yml (implied by writable_config in version 1)"}
+}
@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	//go:embed templates/notify.tmpl
+	notifyStr string
+
+	//go:embed templates/notify-env.tmpl
+	notifyEnvStr string
+
+	NotifyTmpl    = parseTemplate("notify", notifyStr)
+	NotifyEnvTmpl = parseTemplate("notify-env", notifyEnvStr)
+)
+
+const (
+	// NotifyUnit is the template unit shared by every service that sets
+	// notify_on_failure, the instance is the service name.
+	NotifyUnit = "mksvc-notify@.service"
+
+	defaultNotifyLines = 20
+)
+
+// FailureNotify is run by mksvc-notify@.service when the service fails.
+// Exactly one of Webhook and Command is set.
+type FailureNotify struct {
+	Webhook string `yaml:"webhook,omitempty" json:"webhook,omitempty"`
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	Lines   int    `yaml:"lines,omitempty" json:"lines,omitempty"`
+}
+
+func (n *FailureNotify) Validate() error {
+	if (n.Webhook == "") == (n.Command == "") {
+		return fmt.Errorf("notify_on_failure needs exactly one of webhook or command")
+	}
+
+	// Both are embedded in single quotes in the notify environment file
+	if n.Webhook != "" && !healthURLRgx.MatchString(n.Webhook) {
+		return fmt.Errorf("invalid notification webhook %q", n.Webhook)
+	}
+
+	if n.Command != "" && !healthCommandRgx.MatchString(n.Command) {
+		return fmt.Errorf("invalid notification command %q: must be a single line without single quotes", n.Command)
+	}
+
+	if n.Lines < 1 || n.Lines > 1000 {
+		return fmt.Errorf("invalid notification lines %d: must be between 1 and 1000", n.Lines)
+	}
+
+	return nil
+}
+
+func (n *FailureNotify) String() string {
+	if n.Webhook != "" {
+		return "webhook " + n.Webhook
+	}
+
+	return "command " + n.Command
+}
+
+// OnFailureUnits returns the units started when the service fails.
+func (cfg *ServiceConfig) OnFailureUnits() []string {
+	units := slices.Clone(cfg.RestartPolicy().OnFailure)
+
+	if cfg.NotifyOnFailure != nil {
+		units = append(units, "mksvc-notify@"+cfg.Name+".service")
+	}
+
+	return units
+}
+
+// Notification is the JSON body posted to failure webhooks.
+type Notification struct {
+	Unit  string    `json:"unit"`
+	Host  string    `json:"host"`
+	Time  time.Time `json:"time"`
+	Text  string    `json:"text"`
+	Lines []string  `json:"lines"`
+}
+
+func NewNotification(unit string, lines []string) Notification {
+	host, _ := os.Hostname()
+
+	return Notification{
+		Unit:  unit,
+		Host:  host,
+		Time:  time.Now().UTC(),
+		Text:  fmt.Sprintf("%s failed on %s", unit, host),
+		Lines: lines,
+	}
+}
+
+// Post sends the notification to a webhook, any status but 2xx is an error.
+func (n Notification) Post(url string, client *http.Client) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Run passes the notification to a shell command: the unit and host are
+// exported as MKSVC_UNIT and MKSVC_HOST, the journal lines go to stdin. The
+// output of the command is written to stdout and stderr.
+func (n Notification) Run(command string, stdout, stderr io.Writer) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+
+	cmd.Env = append(os.Environ(), "MKSVC_UNIT="+n.Unit, "MKSVC_HOST="+n.Host)
+	cmd.Stdin = strings.NewReader(strings.Join(n.Lines, "\n") + "\n")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	return cmd.Run()
+}
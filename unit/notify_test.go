@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFailureNotifyValidate(t *testing.T) {
+	invalid := []FailureNotify{
+		{Lines: 20},
+		{Webhook: "http://127.0.0.1/hook", Command: "true", Lines: 20},
+		{Webhook: "ftp://example.com/hook", Lines: 20},
+		{Command: "echo 'failed'", Lines: 20},
+		{Command: "true", Lines: 5000},
+	}
+
+	for _, notify := range invalid {
+		if notify.Validate() == nil {
+			t.Errorf("expected %+v to be rejected", notify)
+		}
+	}
+
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.NotifyOnFailure = &FailureNotify{Command: "logger -t mksvc failed"}
+	cfg.Restart = &RestartPolicy{OnFailure: []string{"recovery.target"}}
+
+	if _, err := cfg.Prepare(""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"recovery.target", "mksvc-notify@example.service"}
+
+	if units := cfg.OnFailureUnits(); !slices.Equal(units, expected) {
+		t.Errorf("expected OnFailure units %v, got %v", expected, units)
+	}
+
+	cfg.Backend = "openrc"
+
+	if cfg.Validate() == nil {
+		t.Error("expected notify_on_failure to be rejected for the openrc backend")
+	}
+}
+
+func TestNotificationPost(t *testing.T) {
+	var received Notification
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+
+	notification := NewNotification("example.service", []string{"Main process exited, code=exited, status=1/FAILURE"})
+
+	if err := notification.Post(server.URL, server.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Unit != "example.service" || len(received.Lines) != 1 || !strings.Contains(received.Text, "example.service failed") {
+		t.Errorf("unexpected notification %+v", received)
+	}
+
+	if notification.Post(failing.URL, failing.Client()) == nil {
+		t.Error("expected a 500 response to be an error")
+	}
+}
+
+func TestNotificationRun(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	notification := NewNotification("example.service", []string{"first", "second"})
+
+	if err := notification.Run(`echo "${MKSVC_UNIT}"; cat; echo failed >&2`, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout.String() != "example.service\nfirst\nsecond\n" {
+		t.Errorf("unexpected command input %q", stdout.String())
+	}
+
+	if stderr.String() != "failed\n" {
+		t.Errorf("unexpected command errors %q", stderr.String())
+	}
+}
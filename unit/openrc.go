@@ -0,0 +1,207 @@
+package unit
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	//go:embed templates/openrc-init.tmpl
+	openrcInitStr string
+
+	//go:embed templates/openrc-conf.tmpl
+	openrcConfStr string
+
+	//go:embed templates/openrc-setup.tmpl
+	openrcSetupStr string
+
+	//go:embed templates/openrc-uninstall.tmpl
+	openrcUninstallStr string
+
+	OpenRCInitTmpl      = parseTemplate("openrc-init", openrcInitStr)
+	OpenRCConfTmpl      = parseTemplate("openrc-conf", openrcConfStr)
+	OpenRCSetupTmpl     = parseTemplate("openrc-setup", openrcSetupStr)
+	OpenRCUninstallTmpl = parseTemplate("openrc-uninstall", openrcUninstallStr)
+
+	// rc_ulimit flags for the resource limits mksvc sets by default
+	openrcUlimits = []struct {
+		key  string
+		flag string
+	}{
+		{"LimitNOFILE", "-n"},
+		{"LimitNPROC", "-u"},
+		{"LimitCORE", "-c"},
+	}
+)
+
+// openrcBackend runs the service under supervise-daemon. OpenRC has no
+// namespace or seccomp sandboxing, so only identity, capabilities, limits
+// and cgroup settings carry over from the systemd unit.
+type openrcBackend struct{}
+
+func (openrcBackend) Name() string {
+	return "openrc"
+}
+
+func (openrcBackend) Templates() []ArtifactTemplate {
+	return []ArtifactTemplate{
+		{"initd", "{name}.initd", OpenRCInitTmpl},
+		{"confd", "{name}.confd", OpenRCConfTmpl},
+		{"setup", "setup.sh", OpenRCSetupTmpl},
+		{"uninstall", "uninstall.sh", OpenRCUninstallTmpl},
+		{"logrotate", "{name}_logs.conf", LogrotateTmpl},
+	}
+}
+
+func (openrcBackend) InstalledFiles(cfg *ServiceConfig) map[string]string {
+	return map[string]string{
+		"unit":      "/etc/init.d/" + cfg.Name,
+		"confd":     "/etc/conf.d/" + cfg.Name,
+		"logrotate": "/etc/logrotate.d/" + cfg.Name,
+	}
+}
+
+func (openrcBackend) Unsupported(cfg *ServiceConfig) []string {
+	unsupported := []string{
+		"ProtectSystem=strict and ReadOnlyPaths (the filesystem is writable wherever the service user has permission)",
+		"ProtectHome=yes (home directories are only protected by their permissions)",
+		"PrivateTmp=yes (/tmp and /var/tmp are shared with other services)",
+	}
+
+	if !cfg.Devices {
+		unsupported = append(unsupported, "PrivateDevices=yes and DevicePolicy=closed (/dev is only protected by its permissions)")
+	} else if !cfg.FullDevices {
+		unsupported = append(unsupported, "DeviceAllow (device access is not limited to USB and serial devices)")
+	}
+
+	unsupported = append(unsupported,
+		"ProtectKernel*, ProtectControlGroups and ProtectClock (kernel interfaces are only protected by the missing capabilities)",
+		"ProtectProc=invisible and ProcSubset=pid (other processes are visible in /proc)",
+		"PrivateIPC, RestrictNamespaces, ProtectHostname, LockPersonality, RestrictRealtime and RestrictSUIDSGID",
+		"SystemCallFilter and SystemCallArchitectures (no seccomp filter is applied)",
+		"RestrictAddressFamilies (all socket families are available)",
+	)
+
+	if !cfg.ExecMemory {
+		unsupported = append(unsupported, "MemoryDenyWriteExecute=yes (memory can be mapped writable and executable)")
+	}
+
+	if !cfg.Network {
+		unsupported = append(unsupported, "PrivateNetwork=yes (the service keeps network access)")
+	} else if !cfg.Listening {
+		unsupported = append(unsupported, "SocketBindDeny=any (the service can listen on ports)")
+	}
+
+	if cfg.LocalhostOnly {
+		unsupported = append(unsupported, "IPAddressAllow=localhost (connections are not limited to localhost)")
+	}
+
+	if !cfg.Subprocess {
+		unsupported = append(unsupported, "InaccessiblePaths (shells and system binaries stay executable)")
+	}
+
+	if cfg.PrivateUsers {
+		unsupported = append(unsupported, "PrivateUsers=yes (no user namespace is created)")
+	}
+
+	if cfg.EnvFile != "" {
+		unsupported = append(unsupported, "EnvironmentFile (export the variables in /etc/conf.d/"+cfg.Name+" instead)")
+	}
+
+	if len(cfg.BindsTo) > 0 {
+		unsupported = append(unsupported, "BindsTo (bound services are needed, but not stopped together)")
+	}
+
+	if restart := cfg.Restart; restart != nil {
+		if restart.Mode != "on-failure" && restart.Mode != "always" {
+			unsupported = append(unsupported, "Restart="+restart.Mode+" (supervise-daemon respawns after every exit)")
+		}
+
+		if restart.Steps > 0 {
+			unsupported = append(unsupported, "RestartSteps and RestartMaxDelaySec (respawns always wait respawn_delay)")
+		}
+
+		if len(restart.SuccessExitStatus) > 0 || len(restart.PreventExitStatus) > 0 {
+			unsupported = append(unsupported, "SuccessExitStatus and RestartPreventExitStatus (exit codes are not inspected)")
+		}
+
+		if len(restart.OnFailure) > 0 {
+			unsupported = append(unsupported, "OnFailure (no unit is started when the service fails)")
+		}
+	}
+
+	return unsupported
+}
+
+func (openrcBackend) Commands(name, action string) [][]string {
+	switch action {
+	case ActionRestart:
+		return [][]string{{"rc-service", name, "restart"}}
+	case ActionDisable:
+		return [][]string{
+			{"rc-service", name, "stop"},
+			{"rc-update", "del", name, "default"},
+		}
+	}
+
+	return nil
+}
+
+// OpenRCUnsupported lists the sandbox settings the OpenRC init script cannot
+// express, for its header.
+func (cfg *ServiceConfig) OpenRCUnsupported() []string {
+	return openrcBackend{}.Unsupported(cfg)
+}
+
+// OpenRCServices returns the init script names of systemd service units.
+func (cfg *ServiceConfig) OpenRCServices(units []string) []string {
+	names := make([]string, 0, len(units))
+
+	for _, unit := range units {
+		names = append(names, strings.TrimSuffix(unit, ".service"))
+	}
+
+	return names
+}
+
+// OpenRCUlimit returns the rc_ulimit value for the default resource limits.
+func (cfg *ServiceConfig) OpenRCUlimit() string {
+	var flags []string
+
+	for _, limit := range openrcUlimits {
+		if value, ok := cfg.Defaults[limit.key]; ok {
+			flags = append(flags, limit.flag+" "+value)
+		}
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// OpenRCCgroupSettings returns cgroup v2 controller settings for the CPU
+// quota and memory limit.
+func (cfg *ServiceConfig) OpenRCCgroupSettings() []string {
+	var settings []string
+
+	if cfg.CPUQuota != "" {
+		// Quota in microseconds per 100ms period
+		settings = append(settings, fmt.Sprintf("cpu.max %d 100000", int64(math.Round(cfg.cpuPercent()*1000))))
+	}
+
+	if cfg.MemoryMax != "" {
+		settings = append(settings, fmt.Sprintf("memory.max %d", cfg.memoryMaxBytes()))
+	}
+
+	return settings
+}
+
+// OpenRCRetry returns the stop schedule matching TimeoutStopSec.
+func (cfg *ServiceConfig) OpenRCRetry() string {
+	timeout, ok := cfg.Defaults["TimeoutStopSec"]
+	if !ok {
+		timeout = "90"
+	}
+
+	return "TERM/" + timeout + "/KILL/5"
+}
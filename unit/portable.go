@@ -0,0 +1,225 @@
+package unit
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PortableUnitPath is where portablectl looks for units inside an image.
+const PortableUnitPath = "usr/lib/systemd/system"
+
+// Searched by the dynamic loader after DT_RUNPATH and ld.so.conf
+var defaultLibraryDirs = []string{"/lib64", "/usr/lib64", "/lib", "/usr/lib"}
+
+// PortableWritablePaths returns the host paths the service may write to. They
+// are bind mounted into a portable image, which is read-only otherwise.
+func (cfg *ServiceConfig) PortableWritablePaths() []string {
+	var paths []string
+
+	if cfg.SeparateLogDir {
+		paths = append(paths, pathpkg.Join(cfg.Path, "logs"))
+	} else {
+		paths = append(paths, pathpkg.Join(cfg.Path, cfg.Name+".log"))
+	}
+
+	if cfg.WritableFiles {
+		paths = append(paths, pathpkg.Join(cfg.Path, "data"))
+	}
+
+	if cfg.WritableConfig {
+		paths = append(paths, pathpkg.Join(cfg.Path, cfg.ConfigFile))
+	}
+
+	return paths
+}
+
+// PortableBindPaths returns the BindPaths value of a portable unit.
+func (cfg *ServiceConfig) PortableBindPaths() string {
+	return strings.Join(cfg.PortableWritablePaths(), " ")
+}
+
+// PortableUnit renders the unit embedded in a portable service image. It is
+// the regular unit plus bind mounts for the writable paths; portablectl adds
+// RootDirectory or RootImage when the image is attached.
+func (cfg *ServiceConfig) PortableUnit() ([]byte, error) {
+	backend, err := LookupBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.Name() != DefaultBackend {
+		return nil, fmt.Errorf("portable images need the systemd backend, not %s", backend.Name())
+	}
+
+	portable := *cfg
+	portable.Portable = true
+
+	return portable.renderTemplate(ServiceTmpl)
+}
+
+// CollectLibraries resolves the shared libraries an ELF executable loads, the
+// way ldd does, and returns them with its program interpreter.
+func CollectLibraries(executable string) ([]string, error) {
+	binary, err := elf.Open(executable)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an ELF executable: %w", executable, err)
+	}
+
+	defer binary.Close()
+
+	var libraries []string
+
+	for _, prog := range binary.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+
+		data := make([]byte, prog.Filesz)
+
+		_, err = prog.ReadAt(data, 0)
+		if err != nil {
+			return nil, fmt.Errorf("could not read interpreter of %s: %w", executable, err)
+		}
+
+		libraries = append(libraries, strings.TrimRight(string(data), "\x00"))
+	}
+
+	dirs := append(ldconfigDirs("/etc/ld.so.conf"), defaultLibraryDirs...)
+
+	seen := make(map[string]bool)
+	queue := []string{executable}
+
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+
+		object, err := elf.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		needed, _ := object.ImportedLibraries()
+		search := runPaths(object, path)
+
+		object.Close()
+
+		for _, name := range needed {
+			if seen[name] {
+				continue
+			}
+
+			seen[name] = true
+
+			library := findLibrary(name, append(search, dirs...), binary.Class, binary.Machine)
+			if library == "" {
+				return nil, fmt.Errorf("could not find %s needed by %s", name, path)
+			}
+
+			libraries = append(libraries, library)
+			queue = append(queue, library)
+		}
+	}
+
+	slices.Sort(libraries)
+
+	return slices.Compact(libraries), nil
+}
+
+// runPaths returns the DT_RUNPATH (or DT_RPATH) directories of object.
+func runPaths(object *elf.File, path string) []string {
+	value, _ := object.DynString(elf.DT_RUNPATH)
+	if len(value) == 0 {
+		value, _ = object.DynString(elf.DT_RPATH)
+	}
+
+	return expandRunPaths(value, filepath.Dir(path))
+}
+
+// expandRunPaths splits run path entries and replaces $ORIGIN with the
+// directory of the object. Relative entries are dropped.
+func expandRunPaths(value []string, origin string) []string {
+	var dirs []string
+
+	for _, entry := range value {
+		for dir := range strings.SplitSeq(entry, ":") {
+			dir = strings.ReplaceAll(dir, "${ORIGIN}", origin)
+			dir = strings.ReplaceAll(dir, "$ORIGIN", origin)
+
+			if filepath.IsAbs(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	return dirs
+}
+
+func findLibrary(name string, dirs []string, class elf.Class, machine elf.Machine) string {
+	candidates := dirs
+
+	if strings.Contains(name, "/") {
+		candidates = []string{""}
+	}
+
+	for _, dir := range candidates {
+		candidate := filepath.Join(dir, name)
+
+		object, err := elf.Open(candidate)
+		if err != nil {
+			continue
+		}
+
+		matches := object.Class == class && object.Machine == machine
+
+		object.Close()
+
+		if matches {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// ldconfigDirs returns the library directories listed in an ld.so.conf file
+// and the files it includes.
+func ldconfigDirs(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+
+	for line := range strings.Lines(string(data)) {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+
+		if pattern, ok := strings.CutPrefix(line, "include"); ok && pattern != strings.TrimSpace(pattern) {
+			pattern = strings.TrimSpace(pattern)
+
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+
+			matches, _ := filepath.Glob(pattern)
+
+			for _, match := range matches {
+				dirs = append(dirs, ldconfigDirs(match)...)
+			}
+
+			continue
+		}
+
+		if filepath.IsAbs(line) {
+			dirs = append(dirs, line)
+		}
+	}
+
+	return dirs
+}
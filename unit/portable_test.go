@@ -0,0 +1,193 @@
+package unit
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPortableUnit(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.WritableFiles = true
+	cfg.WritableConfig = true
+	cfg.ConfigFile = "config.yml"
+
+	data, err := cfg.PortableUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ParseUnitFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/opt/example/logs /opt/example/data /opt/example/config.yml"}
+
+	if values := file.Values("Service", "BindPaths"); !slices.Equal(values, expected) {
+		t.Errorf("expected BindPaths %v, got %v", expected, values)
+	}
+
+	if cfg.Portable {
+		t.Error("expected PortableUnit to leave cfg unchanged")
+	}
+
+	cfg.Backend = "openrc"
+
+	if _, err := cfg.PortableUnit(); err == nil {
+		t.Error("expected the openrc backend to be rejected")
+	}
+}
+
+func TestCollectLibraries(t *testing.T) {
+	var executable string
+
+	for _, name := range []string{"ls", "sh", "cat"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		if interpreter(t, path) != "" {
+			executable = path
+
+			break
+		}
+	}
+
+	if executable == "" {
+		t.Skip("no dynamically linked executable available")
+	}
+
+	libraries, err := CollectLibraries(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(libraries, interpreter(t, executable)) {
+		t.Errorf("expected the interpreter in %v", libraries)
+	}
+
+	if !slices.ContainsFunc(libraries, func(library string) bool {
+		return strings.HasPrefix(filepath.Base(library), "libc.so")
+	}) {
+		t.Errorf("expected libc in %v", libraries)
+	}
+
+	for _, library := range libraries {
+		if !filepath.IsAbs(library) {
+			t.Errorf("expected absolute library paths, got %s", library)
+		}
+	}
+}
+
+func TestCollectLibrariesOrigin(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("cc not available")
+	}
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+
+	if err := os.Mkdir(lib, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := filepath.Join(dir, "main.c")
+
+	if err := os.WriteFile(source, []byte("int helper(void);\nint main(void) { return helper(); }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "helper.c"), []byte("int helper(void) { return 0; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	executable := filepath.Join(dir, "example")
+
+	for _, args := range [][]string{
+		{"-shared", "-fPIC", "-o", filepath.Join(lib, "libhelper.so"), filepath.Join(dir, "helper.c")},
+		{"-o", executable, source, "-L" + lib, "-lhelper", "-Wl,-rpath,$ORIGIN/lib"},
+	} {
+		if out, err := exec.Command("cc", args...).CombinedOutput(); err != nil {
+			t.Skipf("cc failed: %v\n%s", err, out)
+		}
+	}
+
+	libraries, err := CollectLibraries(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(libraries, filepath.Join(lib, "libhelper.so")) {
+		t.Errorf("expected libhelper.so from $ORIGIN/lib in %v", libraries)
+	}
+
+	expected := []string{"/opt/example/bin/lib", "/opt/example/bin/../lib", "/usr/lib/example"}
+
+	if dirs := expandRunPaths([]string{"$ORIGIN/lib:${ORIGIN}/../lib", "relative:/usr/lib/example"}, "/opt/example/bin"); !slices.Equal(dirs, expected) {
+		t.Errorf("expected run paths %v, got %v", expected, dirs)
+	}
+}
+
+func TestLdconfigDirs(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "ld.so.conf.d")
+
+	if err := os.Mkdir(confd, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"ld.so.conf":               "# libraries\n/opt/first\ninclude ld.so.conf.d/*.conf\ninclude " + filepath.Join(dir, "extra.conf") + "\nincluded/not/a/dir\n",
+		"ld.so.conf.d/a.conf":      "/opt/a # trailing comment\n",
+		"ld.so.conf.d/b.conf":      "\n/opt/b\nrelative/dir\n",
+		"ld.so.conf.d/ignored.txt": "/opt/ignored\n",
+		"extra.conf":               "/opt/extra\n",
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"/opt/first", "/opt/a", "/opt/b", "/opt/extra"}
+
+	if dirs := ldconfigDirs(filepath.Join(dir, "ld.so.conf")); !slices.Equal(dirs, expected) {
+		t.Errorf("expected %v, got %v", expected, dirs)
+	}
+
+	if dirs := ldconfigDirs(filepath.Join(dir, "missing.conf")); dirs != nil {
+		t.Errorf("expected no directories for a missing file, got %v", dirs)
+	}
+}
+
+func interpreter(t *testing.T, path string) string {
+	t.Helper()
+
+	object, err := elf.Open(path)
+	if err != nil {
+		return ""
+	}
+
+	defer object.Close()
+
+	for _, prog := range object.Progs {
+		if prog.Type == elf.PT_INTERP {
+			data := make([]byte, prog.Filesz)
+
+			if _, err := prog.ReadAt(data, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			return strings.TrimRight(string(data), "\x00")
+		}
+	}
+
+	return ""
+}
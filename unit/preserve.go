@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	managedKeys = initManagedKeys()
+
+	preservedCustomKeys = map[string]bool{
+		"Environment": true,
+	}
+)
+
+// Managed keys are whatever the service template can emit, collected by
+// rendering variants that together switch every conditional directive on.
+func initManagedKeys() map[string]bool {
+	keys := make(map[string]bool)
+
+	variants := []func(cfg *ServiceConfig){
+		func(cfg *ServiceConfig) {},
+		func(cfg *ServiceConfig) {
+			cfg.Network = true
+		},
+		func(cfg *ServiceConfig) {
+			cfg.Devices = true
+		},
+		func(cfg *ServiceConfig) {
+			cfg.Network = true
+			cfg.Listening = true
+			cfg.PrivilegedPorts = true
+			cfg.LocalhostOnly = true
+			cfg.PrivateUsers = true
+			cfg.ExecMemory = true
+			cfg.WritableFiles = true
+			cfg.WritableConfig = true
+			cfg.ConfigFile = "config.yml"
+			cfg.RuntimeDir = true
+			cfg.Devices = true
+			cfg.FullDevices = true
+			cfg.Subprocess = true
+			cfg.CPUQuota = "100%"
+			cfg.MemoryMax = "1G"
+			cfg.EnvFile = "/opt/managed/.env"
+			cfg.Groups = []string{"managed"}
+			cfg.Restart = &RestartPolicy{
+				Steps:             5,
+				MaxDelay:          60,
+				SuccessExitStatus: []string{"SIGTERM"},
+				PreventExitStatus: []string{"2"},
+			}
+
+			cfg.Restart.Normalize()
+		},
+	}
+
+	for _, variant := range variants {
+		cfg := NewServiceConfig("managed", "/opt/managed")
+
+		cfg.Defaults = nil
+
+		variant(cfg)
+
+		data, err := cfg.renderTemplate(ServiceTmpl)
+		if err != nil {
+			panic(err)
+		}
+
+		file, err := ParseUnitFile(data)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, key := range file.Keys("Service") {
+			keys[key] = true
+		}
+	}
+
+	return keys
+}
+
+func (cfg *ServiceConfig) PreserveCustom(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	file, err := ParseUnitFile(data)
+	if err != nil {
+		return err
+	}
+
+	var declared []string
+
+	if match := dependencyMarkerRgx.FindSubmatch(data); match != nil {
+		declared = strings.Fields(string(match[1]))
+	}
+
+	if after := removeManagedTargets(strings.Join(file.Values("Unit", "After"), " "), declared); after != "" {
+		cfg.After = after
+	}
+
+	if requires := removeManagedTargets(strings.Join(file.Values("Unit", "Requires"), " "), declared); requires != "" {
+		cfg.Requires = requires
+	}
+
+	defaults := defaultLimits()
+
+	for _, entry := range file.Entries("Service") {
+		key := entry.Key
+
+		if managedKeys[key] || cfg.Custom[key] != nil {
+			continue
+		}
+
+		values := file.Values("Service", key)
+		if len(values) == 0 || len(values) == 1 && cfg.Defaults[key] == values[0] {
+			continue
+		}
+
+		if _, isDefault := defaults[key]; !isDefault && !preservedCustomKeys[key] {
+			return fmt.Errorf("line %d: refusing to preserve unsupported directive %s", entry.Line, key)
+		}
+
+		cfg.Custom[key] = values
+
+		delete(cfg.Defaults, key)
+	}
+
+	return nil
+}
+
+// removeManagedTargets drops the default targets and the units declared as
+// dependencies in svc.yml, which are added back from the current config.
+func removeManagedTargets(value string, declared []string) string {
+	managed := map[string]bool{
+		"local-fs.target":       true,
+		"network.target":        true,
+		"network-online.target": true,
+	}
+
+	values := strings.Fields(value)
+	kept := values[:0]
+
+	for _, item := range values {
+		if !managed[item] && !slices.Contains(declared, item) {
+			kept = append(kept, item)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func FuzzPreserveCustom(f *testing.F) {
+	f.Add("[Unit]\nAfter=network.target foo.service\n\n[Service]\nEnvironment=A=1\nTimeoutStartSec=600\n")
+	f.Add("[Service]\nEnvironment=\"A=1 B=2\"\nEnvironment=C=3\nLimitNOFILE=65536\n")
+	f.Add("[Unit]\nRequires=local-fs.target\nRequires=bar.service\n[Install]\nWantedBy=multi-user.target\n")
+	f.Add("[Service]\nExecStartPre=/bin/sh\n")
+	f.Add("[Service]\nBad Key=1\n")
+	f.Add("[Service]\r\nEnvironment=A=1\r\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "example.service")
+
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := NewServiceConfig("example", "/opt/example")
+
+		if err := cfg.PreserveCustom(path); err != nil {
+			return
+		}
+
+		for key := range cfg.Custom {
+			if !preservedCustomKeys[key] {
+				if _, isDefault := defaultLimits()[key]; !isDefault {
+					t.Fatalf("preserved unsupported directive %s", key)
+				}
+			}
+		}
+
+		rendered, err := cfg.RenderArtifact("service")
+		if err != nil {
+			t.Fatalf("render: %v", err)
+		}
+
+		if err := os.WriteFile(path, rendered, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		again := NewServiceConfig("example", "/opt/example")
+
+		if err := again.PreserveCustom(path); err != nil {
+			t.Fatalf("rendered unit is not preservable: %v\n%s", err, rendered)
+		}
+
+		if !reflect.DeepEqual(cfg.Custom, again.Custom) || cfg.After != again.After || cfg.Requires != again.Requires {
+			t.Fatalf("preservation is not stable across regeneration\nfirst:  %v %q %q\nsecond: %v %q %q", cfg.Custom, cfg.After, cfg.Requires, again.Custom, again.After, again.Requires)
+		}
+	})
+}
+
+func TestPreserveCustomDefaultLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "example.service")
+
+	unit := "[Service]\nTimeoutStartSec=600\nLimitNOFILE=65536\n"
+
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := NewServiceConfig("example", "/opt/example")
+
+	if err := cfg.PreserveCustom(path); err != nil {
+		t.Fatalf("edited default limit was rejected: %v", err)
+	}
+
+	if got := cfg.Custom["TimeoutStartSec"]; !reflect.DeepEqual(got, []string{"600"}) {
+		t.Fatalf("TimeoutStartSec = %v, want [600]", got)
+	}
+
+	if _, ok := cfg.Defaults["TimeoutStartSec"]; ok {
+		t.Fatal("TimeoutStartSec is still rendered from the defaults")
+	}
+
+	if _, ok := cfg.Custom["LimitNOFILE"]; ok {
+		t.Fatal("unchanged default limit was moved to custom")
+	}
+
+	if err := os.WriteFile(path, []byte("[Service]\nExecStartPre=/bin/true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewServiceConfig("example", "/opt/example").PreserveCustom(path); err == nil {
+		t.Fatal("unsupported directive was preserved")
+	}
+}
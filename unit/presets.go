@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+var (
+	builtinPresets = map[string]*Preset{
+		"web-server": {
+			Description:   "HTTP/API server on a high port behind a reverse proxy",
+			Network:       ptr(true),
+			Listening:     ptr(true),
+			WritableFiles: ptr(true),
+		},
+		"reverse-proxy": {
+			Description:     "Edge proxy binding ports 80/443 directly",
+			Network:         ptr(true),
+			Listening:       ptr(true),
+			PrivilegedPorts: ptr(true),
+			RuntimeDir:      ptr(true),
+		},
+		"worker": {
+			Description:   "Outbound-only queue consumer or bot",
+			Network:       ptr(true),
+			Listening:     ptr(false),
+			WritableFiles: ptr(true),
+		},
+		"cron-job": {
+			Description:   "Offline batch job working on local files",
+			Network:       ptr(false),
+			WritableFiles: ptr(true),
+		},
+		"node-app": {
+			Description:   "Node.js server (JIT needs executable memory)",
+			Network:       ptr(true),
+			Listening:     ptr(true),
+			ExecMemory:    ptr(true),
+			WritableFiles: ptr(true),
+		},
+		"jvm-app": {
+			Description:   "Java/Kotlin server on the JVM",
+			Network:       ptr(true),
+			Listening:     ptr(true),
+			ExecMemory:    ptr(true),
+			WritableFiles: ptr(true),
+			MemoryMax:     ptr("2G"),
+		},
+		"serial-gateway": {
+			Description: "Bridge between USB/serial hardware and the network",
+			Network:     ptr(true),
+			Listening:   ptr(true),
+			Devices:     ptr(true),
+		},
+	}
+)
+
+type Preset struct {
+	Description string `yaml:"description"`
+
+	// Core options
+	Network         *bool   `yaml:"network"`
+	Listening       *bool   `yaml:"listening"`
+	PrivilegedPorts *bool   `yaml:"privileged_ports"`
+	ExecMemory      *bool   `yaml:"exec_memory"`
+	WritableFiles   *bool   `yaml:"writable_files"`
+	WritableConfig  *bool   `yaml:"writable_config"`
+	ConfigFile      *string `yaml:"config_file"`
+	RuntimeDir      *bool   `yaml:"runtime_dir"`
+	Devices         *bool   `yaml:"devices"`
+	FullDevices     *bool   `yaml:"full_devices"`
+	Subprocess      *bool   `yaml:"subprocess"`
+	SeparateLogDir  *bool   `yaml:"separate_log_dir"`
+
+	// Advanced security
+	LocalhostOnly *bool `yaml:"localhost_only"`
+	PrivateUsers  *bool `yaml:"private_users"`
+
+	// Resource limits
+	CPUQuota  *string `yaml:"cpu_quota"`
+	MemoryMax *string `yaml:"memory_max"`
+}
+
+// LoadPresets returns the built-in presets merged with the <name>.yml presets
+// found in dirs. Later directories override earlier ones and built-ins.
+func LoadPresets(dirs ...string) (map[string]*Preset, error) {
+	presets := make(map[string]*Preset, len(builtinPresets))
+
+	for name, preset := range builtinPresets {
+		presets[name] = preset
+	}
+
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".yml")
+
+			if !serviceNameRgx.MatchString(name) {
+				return nil, fmt.Errorf("invalid preset name %q in %s", name, dir)
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			var preset Preset
+
+			if err := yaml.UnmarshalWithOptions(data, &preset, yaml.Strict()); err != nil {
+				return nil, fmt.Errorf("could not load preset %s: %w", file, err)
+			}
+
+			presets[name] = &preset
+		}
+	}
+
+	return presets, nil
+}
+
+func FindPreset(name string, dirs ...string) (*Preset, error) {
+	presets, err := LoadPresets(dirs...)
+	if err != nil {
+		return nil, err
+	}
+
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(presets), ", "))
+	}
+
+	return preset, nil
+}
+
+func (p *Preset) Apply(cfg *ServiceConfig) {
+	set(&cfg.Network, p.Network)
+	set(&cfg.Listening, p.Listening)
+	set(&cfg.PrivilegedPorts, p.PrivilegedPorts)
+	set(&cfg.ExecMemory, p.ExecMemory)
+	set(&cfg.WritableFiles, p.WritableFiles)
+	set(&cfg.WritableConfig, p.WritableConfig)
+	set(&cfg.ConfigFile, p.ConfigFile)
+	set(&cfg.RuntimeDir, p.RuntimeDir)
+	set(&cfg.Devices, p.Devices)
+	set(&cfg.FullDevices, p.FullDevices)
+	set(&cfg.Subprocess, p.Subprocess)
+	set(&cfg.SeparateLogDir, p.SeparateLogDir)
+
+	set(&cfg.LocalhostOnly, p.LocalhostOnly)
+	set(&cfg.PrivateUsers, p.PrivateUsers)
+
+	set(&cfg.CPUQuota, p.CPUQuota)
+	set(&cfg.MemoryMax, p.MemoryMax)
+
+	if cfg.WritableConfig && cfg.ConfigFile == "" {
+		cfg.ConfigFile = "config.yml"
+	}
+}
+
+func PresetNames(presets map[string]*Preset) []string {
+	names := make([]string, 0, len(presets))
+
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func set[T any](dst *T, val *T) {
+	if val != nil {
+		*dst = *val
+	}
+}
+
+func ptr[T any](val T) *T {
+	return &val
+}
@@ -0,0 +1,337 @@
+package unit
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	//go:embed templates/quadlet-container.tmpl
+	quadletContainerStr string
+
+	//go:embed templates/quadlet-setup.tmpl
+	quadletSetupStr string
+
+	//go:embed templates/quadlet-uninstall.tmpl
+	quadletUninstallStr string
+
+	QuadletContainerTmpl = parseTemplate("quadlet-container", quadletContainerStr)
+	QuadletSeccompTmpl   = parseTemplate("quadlet-seccomp", "{{ .SeccompProfile }}")
+	QuadletSetupTmpl     = parseTemplate("quadlet-setup", quadletSetupStr)
+	QuadletUninstallTmpl = parseTemplate("quadlet-uninstall", quadletUninstallStr)
+
+	// Image references are embedded in the .container file unquoted
+	imageRgx = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@-]{0,254}$`)
+
+	// Syscall groups denied by the generated unit, as defined by systemd
+	syscallGroups = map[string][]string{
+		"@clock":         {"adjtimex", "clock_adjtime", "clock_adjtime64", "clock_settime", "clock_settime64", "settimeofday", "stime"},
+		"@cpu-emulation": {"modify_ldt", "subpage_prot", "switch_endian", "vm86", "vm86old"},
+		"@debug":         {"lookup_dcookie", "perf_event_open", "pidfd_getfd", "process_vm_readv", "process_vm_writev", "ptrace", "rtas", "s390_runtime_instr", "sys_debug_setcontext"},
+		"@module":        {"delete_module", "finit_module", "init_module"},
+		"@mount":         {"chroot", "fsconfig", "fsmount", "fsopen", "fspick", "mount", "mount_setattr", "move_mount", "open_tree", "pivot_root", "umount", "umount2"},
+		"@obsolete":      {"_sysctl", "afs_syscall", "bdflush", "break", "create_module", "ftime", "get_kernel_syms", "getpmsg", "gtty", "idle", "lock", "mpx", "prof", "profil", "putpmsg", "query_module", "security", "sgetmask", "ssetmask", "stty", "sysfs", "tuxcall", "ulimit", "uselib", "ustat", "vserver"},
+		"@reboot":        {"kexec_file_load", "kexec_load", "reboot"},
+		"@swap":          {"swapoff", "swapon"},
+		"@resources":     {"ioprio_set", "mbind", "migrate_pages", "move_pages", "nice", "sched_setaffinity", "sched_setattr", "sched_setparam", "sched_setscheduler", "set_mempolicy", "set_mempolicy_home_node", "setpriority", "setrlimit"},
+		"@raw-io":        {"ioperm", "iopl", "pciconfig_iobase", "pciconfig_read", "pciconfig_write", "s390_pci_mmio_read", "s390_pci_mmio_write"},
+		"@privileged":    {"acct", "bpf", "capset", "chown", "chown32", "chroot", "fanotify_init", "fanotify_mark", "fchown", "fchown32", "fchownat", "lchown", "lchown32", "nfsservctl", "open_by_handle_at", "pivot_root", "quotactl", "quotactl_fd", "setdomainname", "setfsuid", "setfsuid32", "setgroups", "setgroups32", "sethostname", "setresuid", "setresuid32", "setreuid", "setreuid32", "setuid", "setuid32", "vhangup"},
+		"@keyring":       {"add_key", "keyctl", "request_key"},
+		"@pkey":          {"pkey_alloc", "pkey_free", "pkey_mprotect"},
+		"@memlock":       {"mlock", "mlock2", "mlockall", "munlock", "munlockall"},
+	}
+
+	// CLONE_NEW* flags that create namespaces. CLONE_NEWTIME overlaps the exit
+	// signal bits of clone and only applies to unshare.
+	cloneNamespaceFlags = []uint64{0x00020000, 0x02000000, 0x04000000, 0x08000000, 0x10000000, 0x20000000, 0x40000000}
+
+	// Ulimit names for the resource limits mksvc sets by default
+	quadletUlimits = []struct {
+		key  string
+		name string
+	}{
+		{"LimitNOFILE", "nofile"},
+		{"LimitNPROC", "nproc"},
+		{"LimitCORE", "core"},
+	}
+)
+
+const (
+	addressFamilyMax = 46
+
+	afUnix    = 1
+	afInet    = 2
+	afInet6   = 10
+	afNetlink = 16
+
+	errnoNoSys = 38
+
+	protWrite = 0x2
+	protExec  = 0x4
+	shmExec   = 0o100000
+)
+
+// quadletBackend runs the service as a rootful Podman container described by
+// a Quadlet .container file. The container runs as the sysusers identity so
+// volumes keep their ownership.
+type quadletBackend struct{}
+
+type seccompProfile struct {
+	DefaultAction string        `json:"defaultAction"`
+	Syscalls      []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Names    []string     `json:"names"`
+	Action   string       `json:"action"`
+	ErrnoRet int          `json:"errnoRet"`
+	Args     []seccompArg `json:"args,omitempty"`
+	Comment  string       `json:"comment,omitempty"`
+}
+
+type seccompArg struct {
+	Index    int    `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+func (quadletBackend) Name() string {
+	return "quadlet"
+}
+
+func (quadletBackend) Templates() []ArtifactTemplate {
+	return []ArtifactTemplate{
+		{"container", "{name}.container", QuadletContainerTmpl},
+		{"seccomp", "{name}-seccomp.json", QuadletSeccompTmpl},
+		{"sysusers", "{name}.conf", UserTmpl},
+		{"setup", "setup.sh", QuadletSetupTmpl},
+		{"uninstall", "uninstall.sh", QuadletUninstallTmpl},
+		{"logrotate", "{name}_logs.conf", LogrotateTmpl},
+	}
+}
+
+func (quadletBackend) InstalledFiles(cfg *ServiceConfig) map[string]string {
+	return map[string]string{
+		"unit":      "/etc/containers/systemd/" + cfg.Name + ".container",
+		"seccomp":   "/etc/containers/mksvc/" + cfg.Name + "-seccomp.json",
+		"sysusers":  "/etc/sysusers.d/" + cfg.Name + ".conf",
+		"logrotate": "/etc/logrotate.d/" + cfg.Name,
+	}
+}
+
+func (quadletBackend) Unsupported(cfg *ServiceConfig) []string {
+	var unsupported []string
+
+	if !cfg.Subprocess {
+		unsupported = append(unsupported, "InaccessiblePaths (the image decides which binaries can be executed)")
+	}
+
+	if cfg.Devices {
+		unsupported = append(unsupported, "DeviceAllow (add AddDevice= lines for the devices the service needs)")
+	}
+
+	if cfg.PrivateUsers {
+		unsupported = append(unsupported, "PrivateUsers=yes (volumes are owned by the service user, so no user namespace is used)")
+	}
+
+	return unsupported
+}
+
+func (quadletBackend) Commands(name, action string) [][]string {
+	switch action {
+	case ActionReload:
+		return [][]string{{"systemctl", "daemon-reload"}}
+	case ActionRestart:
+		return [][]string{{"systemctl", "restart", name}}
+	case ActionDisable:
+		// Units generated by Quadlet cannot be disabled, only stopped
+		return [][]string{{"systemctl", "stop", name}}
+	}
+
+	return nil
+}
+
+// QuadletUnsupported lists the sandbox settings the .container file cannot
+// express, for its header.
+func (cfg *ServiceConfig) QuadletUnsupported() []string {
+	return quadletBackend{}.Unsupported(cfg)
+}
+
+// QuadletUlimits returns Ulimit= values for the default resource limits.
+func (cfg *ServiceConfig) QuadletUlimits() []string {
+	var ulimits []string
+
+	for _, limit := range quadletUlimits {
+		if value, ok := cfg.Defaults[limit.key]; ok {
+			ulimits = append(ulimits, limit.name+"="+value+":"+value)
+		}
+	}
+
+	return ulimits
+}
+
+// QuadletPodmanArgs returns the podman run flags for the CPU quota and
+// memory limit.
+func (cfg *ServiceConfig) QuadletPodmanArgs() string {
+	var args []string
+
+	if cfg.CPUQuota != "" {
+		args = append(args, "--cpus="+strconv.FormatFloat(cfg.cpuPercent()/100, 'f', -1, 64))
+	}
+
+	if cfg.MemoryMax != "" {
+		args = append(args, "--memory="+strconv.FormatInt(cfg.memoryMaxBytes(), 10))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// SeccompProfile returns an OCI seccomp profile matching the unit's
+// SystemCallFilter, RestrictAddressFamilies and MemoryDenyWriteExecute. It
+// lists no architectures, so only native syscalls are allowed.
+func (cfg *ServiceConfig) SeccompProfile() (string, error) {
+	groups := []string{"@clock", "@cpu-emulation", "@debug", "@module", "@mount", "@obsolete", "@reboot", "@swap", "@resources"}
+
+	if !cfg.Devices {
+		groups = append(groups, "@raw-io")
+	}
+
+	groups = append(groups, "@privileged", "@keyring", "@pkey", "@memlock")
+
+	var denied []string
+
+	for _, group := range groups {
+		denied = append(denied, syscallGroups[group]...)
+	}
+
+	slices.Sort(denied)
+
+	profile := seccompProfile{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls: []seccompRule{
+			{
+				Names:    slices.Compact(denied),
+				Action:   "SCMP_ACT_ERRNO",
+				ErrnoRet: 1,
+				Comment:  "SystemCallFilter=~" + strings.Join(groups, " "),
+			},
+		},
+	}
+
+	// The profile replaces Podman's default one, so repeat what that denies
+	// to unprivileged containers as well as RestrictNamespaces=yes. clone3
+	// passes its flags in memory seccomp cannot inspect; ENOSYS makes libc
+	// fall back to clone.
+	profile.Syscalls = append(profile.Syscalls,
+		seccompRule{
+			Names:    []string{"io_uring_enter", "io_uring_register", "io_uring_setup", "userfaultfd"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: 1,
+			Comment:  "Podman default",
+		},
+		seccompRule{
+			Names:    []string{"setns", "unshare"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: 1,
+			Comment:  "RestrictNamespaces",
+		},
+		seccompRule{
+			Names:    []string{"clone3"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: errnoNoSys,
+			Comment:  "RestrictNamespaces",
+		},
+	)
+
+	for _, flag := range cloneNamespaceFlags {
+		profile.Syscalls = append(profile.Syscalls, seccompRule{
+			Names:    []string{"clone"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: 1,
+			Args: []seccompArg{
+				{Index: 0, Value: flag, ValueTwo: flag, Op: "SCMP_CMP_MASKED_EQ"},
+			},
+			Comment: "RestrictNamespaces",
+		})
+	}
+
+	families := []int{afUnix}
+
+	if cfg.Network {
+		families = append(families, afInet, afInet6)
+	}
+
+	if cfg.Devices {
+		families = append(families, afNetlink)
+	}
+
+	for family := range addressFamilyMax {
+		if slices.Contains(families, family) {
+			continue
+		}
+
+		profile.Syscalls = append(profile.Syscalls, seccompRule{
+			Names:    []string{"socket"},
+			Action:   "SCMP_ACT_ERRNO",
+			ErrnoRet: 97,
+			Args: []seccompArg{
+				{Index: 0, Value: uint64(family), Op: "SCMP_CMP_EQ"},
+			},
+			Comment: "RestrictAddressFamilies",
+		})
+	}
+
+	profile.Syscalls = append(profile.Syscalls, seccompRule{
+		Names:    []string{"socket"},
+		Action:   "SCMP_ACT_ERRNO",
+		ErrnoRet: 97,
+		Args: []seccompArg{
+			{Index: 0, Value: addressFamilyMax, Op: "SCMP_CMP_GE"},
+		},
+		Comment: "RestrictAddressFamilies",
+	})
+
+	if !cfg.ExecMemory {
+		profile.Syscalls = append(profile.Syscalls,
+			seccompRule{
+				Names:    []string{"mmap", "mmap2"},
+				Action:   "SCMP_ACT_ERRNO",
+				ErrnoRet: 1,
+				Args: []seccompArg{
+					{Index: 2, Value: protWrite | protExec, ValueTwo: protWrite | protExec, Op: "SCMP_CMP_MASKED_EQ"},
+				},
+				Comment: "MemoryDenyWriteExecute",
+			},
+			seccompRule{
+				Names:    []string{"mprotect", "pkey_mprotect"},
+				Action:   "SCMP_ACT_ERRNO",
+				ErrnoRet: 1,
+				Args: []seccompArg{
+					{Index: 2, Value: protExec, ValueTwo: protExec, Op: "SCMP_CMP_MASKED_EQ"},
+				},
+				Comment: "MemoryDenyWriteExecute",
+			},
+			seccompRule{
+				Names:    []string{"shmat"},
+				Action:   "SCMP_ACT_ERRNO",
+				ErrnoRet: 1,
+				Args: []seccompArg{
+					{Index: 2, Value: shmExec, ValueTwo: shmExec, Op: "SCMP_CMP_MASKED_EQ"},
+				},
+				Comment: "MemoryDenyWriteExecute",
+			},
+		)
+	}
+
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
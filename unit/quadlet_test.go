@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestQuadletImage(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+	cfg.Backend = "quadlet"
+
+	for _, image := range []string{"", "example latest", "-example", "example\nExec=/bin/sh"} {
+		cfg.Image = image
+
+		if cfg.Validate() == nil {
+			t.Errorf("expected image %q to be rejected", image)
+		}
+	}
+
+	cfg.Image = "registry.example.com:5000/team/example@sha256:0123abcd"
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a valid image: %v", err)
+	}
+
+	cfg.Backend = ""
+
+	if cfg.Validate() == nil {
+		t.Error("expected image to be rejected for the systemd backend")
+	}
+}
+
+func TestSeccompProfile(t *testing.T) {
+	rules := func(cfg *ServiceConfig) []string {
+		data, err := cfg.SeccompProfile()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var profile seccompProfile
+
+		err = json.Unmarshal([]byte(data), &profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+
+		for _, rule := range profile.Syscalls {
+			names = append(names, rule.Names...)
+		}
+
+		return names
+	}
+
+	cfg := NewServiceConfig("example", "/opt/example")
+
+	names := rules(cfg)
+
+	for _, name := range []string{"mount", "ptrace", "setuid", "iopl", "mprotect", "socket", "unshare", "setns", "clone", "clone3", "io_uring_setup", "userfaultfd"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("expected %s to be restricted", name)
+		}
+	}
+
+	cfg.ExecMemory = true
+	cfg.Devices = true
+
+	names = rules(cfg)
+
+	for _, name := range []string{"mprotect", "iopl"} {
+		if slices.Contains(names, name) {
+			t.Errorf("expected %s to be allowed", name)
+		}
+	}
+}
+
+func TestSeccompProfileNamespaces(t *testing.T) {
+	data, err := NewServiceConfig("example", "/opt/example").SeccompProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var profile seccompProfile
+
+	err = json.Unmarshal([]byte(data), &profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var masks []uint64
+
+	for _, rule := range profile.Syscalls {
+		if !slices.Contains(rule.Names, "clone") {
+			continue
+		}
+
+		// Plain clone is how threads and processes are created
+		if len(rule.Args) != 1 || rule.Args[0].Op != "SCMP_CMP_MASKED_EQ" {
+			t.Fatalf("clone must only be denied for namespace flags: %+v", rule)
+		}
+
+		masks = append(masks, rule.Args[0].Value)
+	}
+
+	// CLONE_NEWNS, CLONE_NEWUSER and CLONE_NEWNET
+	for _, flag := range []uint64{0x00020000, 0x10000000, 0x40000000} {
+		if !slices.Contains(masks, flag) {
+			t.Errorf("expected clone with flag %#x to be denied", flag)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ReleaseDir is the directory below the service path that deploy keeps
+// releases in.
+const ReleaseDir = "releases"
+
+var releaseRgx = regexp.MustCompile(`^\d{8}T\d{6}Z-([0-9a-f]{12})$`)
+
+// Release is one deployed executable. Sum holds the first 12 hex digits of
+// its SHA-256 checksum, which are part of the directory name.
+type Release struct {
+	ID   string
+	Path string
+	Sum  string
+}
+
+// ReleaseID names a release of an executable with the given checksum.
+func ReleaseID(at time.Time, sum string) string {
+	return at.UTC().Format("20060102T150405Z") + "-" + sum[:12]
+}
+
+// ListReleases returns the releases in dir, oldest first.
+func ListReleases(dir string) ([]Release, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no releases in %s, deploy one first", dir)
+		}
+
+		return nil, err
+	}
+
+	var releases []Release
+
+	for _, entry := range entries {
+		match := releaseRgx.FindStringSubmatch(entry.Name())
+		if match == nil || entry.Type() != os.ModeDir {
+			continue
+		}
+
+		releases = append(releases, Release{
+			ID:   entry.Name(),
+			Path: filepath.Join(dir, entry.Name()),
+			Sum:  match[1],
+		})
+	}
+
+	return releases, nil
+}
+
+// FindRelease returns the newest release with the given checksum.
+func FindRelease(releases []Release, sum string) *Release {
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].Sum == sum[:12] {
+			return &releases[i]
+		}
+	}
+
+	return nil
+}
+
+// RollbackTarget picks the release to roll back to: the one named to, or
+// the newest release before the active one (identified by its checksum)
+// that holds a different executable.
+func RollbackTarget(releases []Release, active, to string) (*Release, error) {
+	if to != "" {
+		for i := range releases {
+			if releases[i].ID == to {
+				return &releases[i], nil
+			}
+		}
+
+		return nil, fmt.Errorf("unknown release %s", to)
+	}
+
+	current := FindRelease(releases, active)
+	if current == nil {
+		return nil, fmt.Errorf("the active executable does not match any release")
+	}
+
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].ID < current.ID && releases[i].Sum != current.Sum {
+			return &releases[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no release before %s to roll back to", current.ID)
+}
+
+// PruneReleases removes the oldest releases in dir until keep are left and
+// returns the IDs it removed. The active release (identified by its
+// checksum) is never removed but counts towards keep.
+func PruneReleases(dir string, keep int, active string) ([]string, error) {
+	releases, err := ListReleases(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	count := len(releases)
+
+	for _, old := range releases {
+		if count <= keep {
+			break
+		}
+
+		if old.Sum == active[:12] {
+			continue
+		}
+
+		err = os.RemoveAll(old.Path)
+		if err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, old.ID)
+
+		count--
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,169 @@
+package unit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func releaseSum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// createReleases stores one release per content, a minute apart and oldest
+// first, and returns their IDs.
+func createReleases(t *testing.T, dir string, contents ...string) []string {
+	t.Helper()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var ids []string
+
+	for i, content := range contents {
+		id := ReleaseID(start.Add(time.Duration(i)*time.Minute), releaseSum(content))
+
+		if err := os.Mkdir(filepath.Join(dir, id), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestListReleases(t *testing.T) {
+	dir := t.TempDir()
+
+	ids := createReleases(t, dir, "b", "a")
+
+	// Ignored: not a release name, and a file with a release name
+	if err := os.Mkdir(filepath.Join(dir, "other"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ReleaseID(time.Now(), releaseSum("c"))), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := ListReleases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+
+	for _, release := range releases {
+		got = append(got, release.ID)
+	}
+
+	if !slices.Equal(got, ids) {
+		t.Errorf("got releases %v, want %v", got, ids)
+	}
+
+	if _, err := ListReleases(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected a missing release directory to fail")
+	}
+}
+
+func TestPruneReleases(t *testing.T) {
+	cases := []struct {
+		name   string
+		keep   int
+		active string
+		want   []int
+	}{
+		{"newest-active", 2, "d", []int{2, 3}},
+		{"old-active", 2, "a", []int{0, 3}},
+		{"keep-one-old-active", 1, "b", []int{1}},
+		{"fewer-than-keep", 5, "d", []int{0, 1, 2, 3}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			ids := createReleases(t, dir, "a", "b", "c", "d")
+
+			if _, err := PruneReleases(dir, tc.keep, releaseSum(tc.active)); err != nil {
+				t.Fatal(err)
+			}
+
+			releases, err := ListReleases(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want []string
+
+			for _, release := range releases {
+				got = append(got, release.ID)
+			}
+
+			for _, index := range tc.want {
+				want = append(want, ids[index])
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRollbackTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	ids := createReleases(t, dir, "a", "b", "c")
+
+	releases, err := ListReleases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := RollbackTarget(releases, releaseSum("c"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target.ID != ids[1] {
+		t.Errorf("rollback from c: got %s, want %s", target.ID, ids[1])
+	}
+
+	// A second rollback continues from the release it activated
+	target, err = RollbackTarget(releases, releaseSum("b"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target.ID != ids[0] {
+		t.Errorf("rollback from b: got %s, want %s", target.ID, ids[0])
+	}
+
+	if _, err := RollbackTarget(releases, releaseSum("a"), ""); err == nil {
+		t.Error("expected no release before the oldest one")
+	}
+
+	target, err = RollbackTarget(releases, releaseSum("a"), ids[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target.ID != ids[2] {
+		t.Errorf("--to: got %s, want %s", target.ID, ids[2])
+	}
+
+	if _, err := RollbackTarget(releases, releaseSum("c"), "20250101T000000Z-000000000000"); err == nil {
+		t.Error("expected an unknown --to release to be rejected")
+	}
+
+	if _, err := RollbackTarget(releases, releaseSum("x"), ""); err == nil {
+		t.Error("expected an executable outside the releases to be rejected")
+	}
+}
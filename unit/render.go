@@ -0,0 +1,256 @@
+package unit
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var (
+	//go:embed templates/service.tmpl
+	serviceStr string
+
+	//go:embed templates/user.tmpl
+	userStr string
+
+	//go:embed templates/setup.tmpl
+	setupStr string
+
+	//go:embed templates/uninstall.tmpl
+	uninstallStr string
+
+	//go:embed templates/logrotate.tmpl
+	logrotateStr string
+
+	//go:embed templates/common.tmpl
+	commonStr string
+
+	ServiceTmpl   = parseTemplate("service", serviceStr)
+	UserTmpl      = parseTemplate("user", userStr)
+	SetupTmpl     = parseTemplate("setup", setupStr)
+	UninstallTmpl = parseTemplate("uninstall", uninstallStr)
+	LogrotateTmpl = parseTemplate("logrotate", logrotateStr)
+)
+
+type Artifact struct {
+	Path string
+	Mode os.FileMode
+	Data []byte
+}
+
+// Render validates cfg and renders every artifact, keyed by file name
+// relative to the conf directory. cfg should be normalized first.
+func Render(cfg *ServiceConfig) (map[string][]byte, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := cfg.Artifacts("")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(artifacts))
+
+	for _, artifact := range artifacts {
+		files[artifact.Path] = artifact.Data
+	}
+
+	return files, nil
+}
+
+// parseTemplate parses text together with the shell snippets shared by the
+// setup and uninstall scripts of every backend.
+func parseTemplate(name, text string) *template.Template {
+	tmpl := template.Must(template.New(name).Parse(text))
+
+	return template.Must(tmpl.Parse(commonStr))
+}
+
+func (cfg *ServiceConfig) renderTemplate(tmpl *template.Template) ([]byte, error) {
+	var data bytes.Buffer
+
+	if err := tmpl.Execute(&data, cfg); err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+func (cfg *ServiceConfig) Artifacts(confDir string) ([]Artifact, error) {
+	config, err := cfg.MarshalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []Artifact{
+		{
+			Path: pathpkg.Join(confDir, "svc.yml"),
+			Mode: 0600,
+			Data: config,
+		},
+	}
+
+	backend, err := LookupBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range backend.Templates() {
+		data, err := cfg.renderTemplate(entry.Tmpl)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) == 0 {
+			continue
+		}
+
+		artifacts = append(artifacts, Artifact{
+			Path: pathpkg.Join(confDir, strings.Replace(entry.File, "{name}", cfg.Name, 1)),
+			Mode: 0644,
+			Data: data,
+		})
+	}
+
+	return artifacts, nil
+}
+
+func (cfg *ServiceConfig) RenderArtifact(kind string) ([]byte, error) {
+	backend, err := LookupBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range backend.Templates() {
+		if entry.Kind == kind {
+			return cfg.renderTemplate(entry.Tmpl)
+		}
+	}
+
+	return nil, fmt.Errorf("the %s backend has no %s artifact", backend.Name(), kind)
+}
+
+func (cfg *ServiceConfig) FormatDefaults() string {
+	return formatMap(cfg.Defaults)
+}
+
+func (cfg *ServiceConfig) FormatCustom() string {
+	keys := make([]string, 0, len(cfg.Custom))
+
+	for k := range cfg.Custom {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var lines []string
+
+	for _, k := range keys {
+		for _, v := range cfg.Custom[k] {
+			lines = append(lines, k+"="+v)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func WriteArtifacts(dir string, artifacts []Artifact) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		err = os.Mkdir(dir, 0755)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s must be a real directory", dir)
+	}
+
+	for _, artifact := range artifacts {
+		err = WriteFileAtomic(artifact.Path, artifact.Data, artifact.Mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	file, err := os.CreateTemp(pathpkg.Dir(path), ".mksvc-*")
+	if err != nil {
+		return err
+	}
+
+	tempPath := file.Name()
+
+	defer os.Remove(tempPath)
+
+	err = file.Chmod(mode)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempPath, path)
+	if err == nil || runtime.GOOS != "windows" {
+		return err
+	}
+
+	info, statErr := os.Lstat(path)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	if statErr == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("refusing to replace non-regular file %s", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tempPath, path)
+}
+
+func formatMap(m map[string]string) string {
+	lines := make([]string, 0, len(m))
+
+	for k, v := range m {
+		lines = append(lines, k+"="+v)
+	}
+
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,26 @@
+package unit
+
+import "testing"
+
+func TestRenderValidates(t *testing.T) {
+	cfg := NewServiceConfig("example", "/opt/example")
+
+	if _, err := Render(cfg); err != nil {
+		t.Fatalf("expected valid config to render: %v", err)
+	}
+
+	for _, path := range []string{"/opt/example; rm -rf /", "/opt/$(id)", "/etc/example", "relative"} {
+		cfg.Path = path
+
+		if _, err := Render(cfg); err == nil {
+			t.Errorf("expected path %q to be rejected", path)
+		}
+	}
+
+	cfg.Path = "/opt/example"
+	cfg.Name = "example$(id)"
+
+	if _, err := Render(cfg); err == nil {
+		t.Error("expected unsafe name to be rejected")
+	}
+}
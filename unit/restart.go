@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	restartModes = []string{"no", "on-success", "on-failure", "on-abnormal", "on-watchdog", "on-abort", "always"}
+
+	exitStatusRgx = regexp.MustCompile(`^(?:[0-9]{1,3}|SIG[A-Z0-9]+)$`)
+	unitNameRgx   = regexp.MustCompile(`^[A-Za-z0-9:_.-]+(?:@[A-Za-z0-9:_.-]+)?\.(?:service|target)$`)
+)
+
+const (
+	defaultRestartMode     = "on-failure"
+	defaultRestartDelay    = 3
+	defaultRestartBurst    = 10
+	defaultRestartInterval = 60
+)
+
+// RestartPolicy controls when systemd restarts the service and how often it
+// may be started before giving up. Durations are in seconds, zero values
+// keep the defaults.
+type RestartPolicy struct {
+	Mode              string   `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Delay             int      `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Steps             int      `yaml:"steps,omitempty" json:"steps,omitempty"`
+	MaxDelay          int      `yaml:"max_delay,omitempty" json:"max_delay,omitempty"`
+	Burst             int      `yaml:"burst,omitempty" json:"burst,omitempty"`
+	Interval          int      `yaml:"interval,omitempty" json:"interval,omitempty"`
+	SuccessExitStatus []string `yaml:"success_exit_status,omitempty" json:"success_exit_status,omitempty"`
+	PreventExitStatus []string `yaml:"prevent_exit_status,omitempty" json:"prevent_exit_status,omitempty"`
+	OnFailure         []string `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+}
+
+// RestartModes returns the values accepted for restart.mode.
+func RestartModes() []string {
+	return slices.Clone(restartModes)
+}
+
+func (r *RestartPolicy) Normalize() {
+	if r.Mode == "" {
+		r.Mode = defaultRestartMode
+	}
+
+	if r.Delay == 0 {
+		r.Delay = defaultRestartDelay
+	}
+
+	if r.Burst == 0 {
+		r.Burst = defaultRestartBurst
+	}
+
+	if r.Interval == 0 {
+		r.Interval = defaultRestartInterval
+	}
+}
+
+func (r *RestartPolicy) Validate(name string) error {
+	if !slices.Contains(restartModes, r.Mode) {
+		return fmt.Errorf("invalid restart mode %q (available: %s)", r.Mode, strings.Join(restartModes, ", "))
+	}
+
+	if r.Delay < 1 || r.Delay > 3600 {
+		return fmt.Errorf("invalid restart delay %d: must be between 1 and 3600 seconds", r.Delay)
+	}
+
+	if r.Burst < 1 || r.Burst > 1000 {
+		return fmt.Errorf("invalid restart burst %d: must be between 1 and 1000", r.Burst)
+	}
+
+	if r.Interval < 1 || r.Interval > 86400 {
+		return fmt.Errorf("invalid restart interval %d: must be between 1 and 86400 seconds", r.Interval)
+	}
+
+	if r.Steps < 0 || r.Steps > 100 {
+		return fmt.Errorf("invalid restart steps %d: must be at most 100", r.Steps)
+	}
+
+	if (r.Steps == 0) != (r.MaxDelay == 0) {
+		return fmt.Errorf("restart steps and max_delay must be set together")
+	}
+
+	if r.Steps > 0 && (r.MaxDelay <= r.Delay || r.MaxDelay > 86400) {
+		return fmt.Errorf("invalid restart max_delay %d: must be above the delay (%ds) and at most 86400 seconds", r.MaxDelay, r.Delay)
+	}
+
+	if r.Mode == "no" && (r.Steps > 0 || len(r.PreventExitStatus) > 0) {
+		return fmt.Errorf("restart steps and prevent_exit_status need a restart mode other than no")
+	}
+
+	for _, status := range append(slices.Clone(r.SuccessExitStatus), r.PreventExitStatus...) {
+		if !exitStatusRgx.MatchString(status) {
+			return fmt.Errorf("invalid exit status %q: must be a number or signal name", status)
+		}
+
+		if code, err := strconv.Atoi(status); err == nil && code > 255 {
+			return fmt.Errorf("invalid exit status %q: must be between 0 and 255", status)
+		}
+	}
+
+	for _, unit := range r.OnFailure {
+		if !unitNameRgx.MatchString(unit) {
+			return fmt.Errorf("invalid on_failure unit %q", unit)
+		}
+
+		if unit == name+".service" {
+			return fmt.Errorf("on_failure cannot name the service itself")
+		}
+	}
+
+	return nil
+}
+
+// Warnings reports settings that are valid but probably not what was meant.
+func (r *RestartPolicy) Warnings() []string {
+	var warnings []string
+
+	// With backoff the delay only grows, so the plain delay is the lower bound
+	if r.Mode != "no" && r.Delay*r.Burst >= r.Interval {
+		warnings = append(warnings, fmt.Sprintf("restart burst %d with a %ds delay never reaches the start limit within %ds", r.Burst, r.Delay, r.Interval))
+	}
+
+	return warnings
+}
+
+// RestartPolicy returns the effective restart policy.
+func (cfg *ServiceConfig) RestartPolicy() *RestartPolicy {
+	if cfg.Restart != nil {
+		return cfg.Restart
+	}
+
+	policy := &RestartPolicy{}
+	policy.Normalize()
+
+	return policy
+}
@@ -0,0 +1,58 @@
+package unit
+
+import "testing"
+
+func TestRestartPolicyValidate(t *testing.T) {
+	invalid := []RestartPolicy{
+		{Mode: "sometimes"},
+		{Delay: 7200},
+		{Steps: 5},
+		{MaxDelay: 60},
+		{Delay: 10, Steps: 5, MaxDelay: 10},
+		{Mode: "no", PreventExitStatus: []string{"2"}},
+		{SuccessExitStatus: []string{"256"}},
+		{SuccessExitStatus: []string{"sigterm"}},
+		{OnFailure: []string{"notify"}},
+		{OnFailure: []string{"example.service"}},
+		{OnFailure: []string{"alert@.service"}},
+	}
+
+	for _, policy := range invalid {
+		policy.Normalize()
+
+		if policy.Validate("example") == nil {
+			t.Errorf("expected %+v to be rejected", policy)
+		}
+	}
+
+	valid := []RestartPolicy{
+		{},
+		{Mode: "always", Delay: 1, Steps: 5, MaxDelay: 300},
+		{SuccessExitStatus: []string{"143", "SIGTERM"}, PreventExitStatus: []string{"2"}},
+		{Mode: "no", OnFailure: []string{"alert@example.service", "recovery.target"}},
+	}
+
+	for _, policy := range valid {
+		policy.Normalize()
+
+		if err := policy.Validate("example"); err != nil {
+			t.Errorf("expected %+v to be valid: %v", policy, err)
+		}
+	}
+}
+
+func TestRestartPolicyWarnings(t *testing.T) {
+	policy := RestartPolicy{Delay: 10}
+	policy.Normalize()
+
+	if len(policy.Warnings()) != 1 {
+		t.Errorf("expected a warning for 10 starts 10s apart within 60s, got %v", policy.Warnings())
+	}
+
+	policy = RestartPolicy{}
+	policy.Normalize()
+
+	if warnings := policy.Warnings(); len(warnings) != 0 {
+		t.Errorf("expected no warnings for the default policy, got %v", warnings)
+	}
+}
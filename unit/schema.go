@@ -0,0 +1,161 @@
+package unit
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var (
+	// SchemaURL is referenced by the header of saved configurations.
+	SchemaURL = "https://github.com/coalaura/mksvc/releases/latest/download/svc.schema.json"
+
+	schemaPatterns = map[string]*regexp.Regexp{
+		"name":        serviceNameRgx,
+		"path":        safePathRgx,
+		"config_file": configFileRgx,
+		"cpu_quota":   cpuQuotaRgx,
+		"memory_max":  memoryMaxRgx,
+		"env_file":    safePathRgx,
+		"http":        healthURLRgx,
+		"tcp":         healthAddressRgx,
+		"unix":        safePathRgx,
+		"command":     healthCommandRgx,
+		"image":       imageRgx,
+	}
+
+	// Descriptions of options that have no CLI flag
+	schemaDescriptions = map[string]string{
+		"Health":  "Check run by setup.sh after starting the service; failures restore the previous unit files.",
+		"HTTP":    "URL that must answer with a 2xx status.",
+		"TCP":     "host:port that must accept connections.",
+		"Unix":    "Path of a unix socket that must accept connections.",
+		"Command": "Command run as the service user that must exit 0.",
+		"Timeout": "Seconds to wait for the service to become healthy (default: 30).",
+
+		"AllowedDevices": "Devices the service may access; narrows DeviceAllow and generates udev rules (requires devices).",
+		"Subsystem":      "udev subsystem, e.g. tty or hidraw.",
+		"Vendor":         "USB vendor id (idVendor), 4 lowercase hex digits.",
+		"Product":        "USB product id (idProduct), 4 lowercase hex digits.",
+
+		"Restart":           "When systemd restarts the service and how often it may start before giving up.",
+		"Mode":              "Restart= mode (default: on-failure).",
+		"Delay":             "Seconds to wait before a restart (default: 3).",
+		"Steps":             "Number of steps to grow the delay to max_delay (requires max_delay).",
+		"MaxDelay":          "Longest delay in seconds reached after steps restarts.",
+		"Burst":             "Starts allowed within interval before the unit fails (default: 10).",
+		"Interval":          "Start limit window in seconds (default: 60).",
+		"SuccessExitStatus": "Exit codes or signal names that count as a clean exit.",
+		"PreventExitStatus": "Exit codes or signal names that never cause a restart.",
+		"OnFailure":         "Units started when the service enters the failed state.",
+
+		"NotifyOnFailure": "Notification sent by mksvc-notify@.service when the service fails (systemd backend).",
+		"Webhook":         "URL that receives a JSON POST with the unit, host and last journal lines.",
+		"Lines":           "Number of journal lines to include (default: 20).",
+	}
+)
+
+// Schema returns a JSON Schema for svc.yml. descriptions maps ServiceConfig
+// field names to property descriptions.
+func Schema(descriptions map[string]string) map[string]any {
+	properties := schemaProperties(reflect.TypeFor[ServiceConfig](), descriptions)
+
+	properties["backend"].(map[string]any)["enum"] = Backends()
+
+	device := properties["allowed_devices"].(map[string]any)["items"].(map[string]any)["properties"].(map[string]any)
+	device["subsystem"].(map[string]any)["enum"] = DeviceSubsystems()
+	device["vendor"].(map[string]any)["pattern"] = usbIDRgx.String()
+	device["product"].(map[string]any)["pattern"] = usbIDRgx.String()
+	device["path"].(map[string]any)["pattern"] = safePathRgx.String()
+	device["path"].(map[string]any)["description"] = "Device node or udev symlink below /dev."
+
+	restart := properties["restart"].(map[string]any)["properties"].(map[string]any)
+	restart["mode"].(map[string]any)["enum"] = RestartModes()
+	restart["success_exit_status"].(map[string]any)["items"].(map[string]any)["pattern"] = exitStatusRgx.String()
+	restart["prevent_exit_status"].(map[string]any)["items"].(map[string]any)["pattern"] = exitStatusRgx.String()
+	restart["on_failure"].(map[string]any)["items"].(map[string]any)["pattern"] = unitNameRgx.String()
+
+	notify := properties["notify_on_failure"].(map[string]any)["properties"].(map[string]any)
+	notify["webhook"].(map[string]any)["pattern"] = healthURLRgx.String()
+	notify["command"].(map[string]any)["description"] = "Shell command run with MKSVC_UNIT and MKSVC_HOST set and the journal lines on stdin."
+
+	for _, key := range []string{"depends_on", "wants", "binds_to", "before"} {
+		list := properties[key].(map[string]any)
+		list["uniqueItems"] = true
+		list["items"].(map[string]any)["pattern"] = `^[A-Za-z0-9:_.@-]+$`
+	}
+
+	groups := properties["groups"].(map[string]any)
+	groups["uniqueItems"] = true
+	groups["items"].(map[string]any)["pattern"] = groupNameRgx.String()
+
+	properties["version"] = map[string]any{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     ConfigVersion,
+		"description": "Schema version of this file, upgraded by 'mksvc migrate'.",
+	}
+
+	return map[string]any{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  SchemaURL,
+		"title":                "mksvc service configuration (svc.yml)",
+		"type":                 "object",
+		"required":             []string{"name", "path"},
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+func schemaProperties(typ reflect.Type, descriptions map[string]string) map[string]any {
+	properties := make(map[string]any)
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		property := schemaType(field.Type, descriptions)
+
+		if rgx, ok := schemaPatterns[key]; ok {
+			property["pattern"] = rgx.String()
+		}
+
+		if desc, ok := descriptions[field.Name]; ok {
+			property["description"] = desc
+		} else if desc, ok := schemaDescriptions[field.Name]; ok {
+			property["description"] = desc
+		}
+
+		properties[key] = property
+	}
+
+	return properties
+}
+
+func schemaType(typ reflect.Type, descriptions map[string]string) map[string]any {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return schemaType(typ.Elem(), descriptions)
+	case reflect.Struct:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           schemaProperties(typ, descriptions),
+		}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": schemaType(typ.Elem(), descriptions),
+		}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
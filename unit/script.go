@@ -0,0 +1,47 @@
+package unit
+
+import (
+	pathpkg "path"
+	"strings"
+)
+
+// Options of env(1) that take a separate argument
+var envArgOptions = map[string]bool{
+	"-u":      true,
+	"--unset": true,
+	"-C":      true,
+	"--chdir": true,
+}
+
+// ScriptInterpreter returns the program name a shebang line runs. For
+// "#!/usr/bin/env" it skips env's own options (including -S, which splits the
+// rest of the line) and variable assignments.
+func ScriptInterpreter(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := pathpkg.Base(fields[0])
+	if interpreter != "env" {
+		return interpreter
+	}
+
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+
+		switch {
+		case strings.HasPrefix(field, "-S") && len(field) > 2:
+			return pathpkg.Base(field[2:])
+		case strings.HasPrefix(field, "-"):
+			if envArgOptions[field] {
+				i++
+			}
+		case strings.Contains(field, "="):
+		default:
+			return pathpkg.Base(field)
+		}
+	}
+
+	return interpreter
+}
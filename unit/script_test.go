@@ -0,0 +1,24 @@
+package unit
+
+import "testing"
+
+func TestScriptInterpreter(t *testing.T) {
+	cases := map[string]string{
+		"#!/bin/sh": "sh",
+		"#!/usr/bin/node --max-old-space-size=512":        "node",
+		"#!/usr/bin/env node":                             "node",
+		"#!/usr/bin/env node --max-old-space-size=512":    "node",
+		"#!/usr/bin/env -S python3 -u":                    "python3",
+		"#!/usr/bin/env -Sdeno run --allow-net":           "deno",
+		"#!/usr/bin/env -u HOME -i NODE_ENV=prod node":    "node",
+		"#!/usr/bin/env --chdir=/tmp java -jar app.jar":   "java",
+		"#!/usr/bin/env -C /srv/app /usr/local/bin/bun x": "bun",
+		"#!": "",
+	}
+
+	for line, want := range cases {
+		if got := ScriptInterpreter(line); got != want {
+			t.Errorf("%q: got %q, want %q", line, got, want)
+		}
+	}
+}
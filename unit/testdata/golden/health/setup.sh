@@ -0,0 +1,303 @@
+#!/bin/bash
+
+set -euo pipefail
+
+if [ "${EUID}" -ne 0 ]; then
+    echo "Run this script as root." >&2
+    exit 1
+fi
+
+name="example"
+path="/opt/example"
+conf_dir="${path}/conf"
+sysusers_file="/etc/sysusers.d/${name}.conf"
+udev_rules="/etc/udev/rules.d/99-${name}.rules"
+notify_env="/etc/mksvc/notify.d/${name}.env"
+notify_unit="/etc/systemd/system/mksvc-notify@.service"
+backup_base="/var/backups/mksvc"
+backup_root="${backup_base}/${name}"
+
+if [ -L "${path}" ] || [ ! -d "${path}" ]; then
+    echo "Service path must be an existing, real directory: ${path}" >&2
+    exit 1
+fi
+
+script_root=$(realpath "$(dirname "${BASH_SOURCE[0]}")/..")
+service_root=$(realpath "${path}")
+
+if [ "${script_root}" != "${service_root}" ]; then
+    echo "Run the setup script from ${path}/conf." >&2
+    exit 1
+fi
+
+for file in "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" "${conf_dir}/${name}_logs.conf"; do
+    if [ -L "${file}" ] || [ ! -f "${file}" ]; then
+        echo "Missing or unsafe generated file: ${file}" >&2
+        exit 1
+    fi
+done
+
+if [ -L "${path}/${name}" ] || [ ! -f "${path}/${name}" ]; then
+    echo "Missing or unsafe service executable: ${path}/${name}" >&2
+    exit 1
+fi
+
+# Rules written by hand are never replaced or removed
+udev_rules_generated() {
+    [ -f "${udev_rules}" ] && [ ! -L "${udev_rules}" ] && head -n 1 "${udev_rules}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+notify_env_generated() {
+    [ -f "${notify_env}" ] && [ ! -L "${notify_env}" ] && head -n 1 "${notify_env}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+# The notification unit is shared by all services with notify_on_failure
+notify_unit_generated() {
+    [ -f "${notify_unit}" ] && [ ! -L "${notify_unit}" ] && head -n 1 "${notify_unit}" | grep -q "^# Generated by mksvc\\. "
+}
+
+if ! command -v curl >/dev/null 2>&1; then
+    echo "The HTTP health check needs curl." >&2
+    exit 1
+fi
+
+health_check() {
+    curl -fsS --max-time 5 -o /dev/null 'http://127.0.0.1:8080/health'
+}
+
+wait_healthy() {
+    local deadline=$((SECONDS + 30))
+
+    until systemctl is-active --quiet "${name}" && health_check >/dev/null 2>&1; do
+        if [ "${SECONDS}" -ge "${deadline}" ]; then
+            return 1
+        fi
+
+        sleep 1
+    done
+}
+
+# Puts back every file listed in the backup manifest (the one mksvc restore
+# reads) and removes the ones that did not exist before
+restore_backup_files() {
+    local kind file stored
+
+    while read -r kind file stored; do
+        case "${kind}" in
+            file)
+                install -o root -g root -m "$(stat -c '%a' "${backup_dir}/${stored}")" "${backup_dir}/${stored}" "${file}"
+                ;;
+            absent)
+                rm -f "${file}"
+                ;;
+        esac
+    done < "${manifest}"
+}
+
+restore_previous() {
+    echo "Restoring previously installed files..." >&2
+
+    if [ ! -f "${backup_dir}/unit" ]; then
+        systemctl disable --now "${name}" 2>/dev/null || true
+    fi
+
+    restore_backup_files
+
+    # sysusers only adds memberships, drop the ones this run added
+    for group in $(awk -v user="${name}" '$1 == "m" && $2 == user { print $3 }' "${conf_dir}/${name}.conf"); do
+        if [ ! -f "${sysusers_file}" ] || ! grep -qx "m ${name} ${group}" "${sysusers_file}"; then
+            gpasswd -d "${name}" "${group}" >/dev/null 2>&1 || true
+        fi
+    done
+
+    if [ -f "${sysusers_file}" ]; then
+        systemd-sysusers "${sysusers_file}"
+    fi
+
+    udevadm control --reload-rules 2>/dev/null || true
+    systemctl daemon-reload
+
+    if [ -f "${backup_dir}/unit" ]; then
+        systemctl restart "${name}" || true
+    fi
+}
+
+echo "Backing up installed files..."
+
+for dir in "${backup_base}" "${backup_root}"; do
+    if [ -L "${dir}" ] || { [ -e "${dir}" ] && [ ! -d "${dir}" ]; }; then
+        echo "Refusing unsafe backup directory: ${dir}" >&2
+        exit 1
+    fi
+done
+
+install -d -o root -g root -m 0700 "${backup_base}" "${backup_root}"
+
+backup_dir=$(mktemp -d "${backup_root}/$(date -u +%Y%m%dT%H%M%SZ)-XXXXXX")
+manifest="${backup_dir}/manifest"
+
+echo "# mksvc backup of ${name}" > "${manifest}"
+
+backup_file() {
+    if [ -f "${1}" ] && [ ! -L "${1}" ]; then
+        cp "${1}" "${backup_dir}/${2}"
+        echo "file ${1} ${2}" >> "${manifest}"
+    else
+        echo "absent ${1}" >> "${manifest}"
+    fi
+}
+
+backup_file "/etc/systemd/system/${name}.service" unit
+backup_file "${sysusers_file}" sysusers
+backup_file "/etc/logrotate.d/${name}" logrotate
+backup_file "${udev_rules}" udev
+backup_file "${notify_env}" notify
+
+for target in "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" \
+    "${conf_dir}/${name}_logs.conf" "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml" \
+    "${path}/logs" "${path}/logs/${name}.log" "${path}/${name}.log" "${path}/data" \
+    "${path}/releases"; do
+    if [ -e "${target}" ] && [ ! -L "${target}" ]; then
+        stat -c 'stat %n %a %u %g' "${target}" >> "${manifest}"
+    fi
+done
+
+# Keep the 10 newest backups
+find "${backup_root}" -mindepth 1 -maxdepth 1 -type d -printf '%f\n' | sort | head -n -10 | while read -r old; do
+    rm -rf "${backup_root:?}/${old}"
+done
+
+echo "Backup written to ${backup_dir}"
+
+echo "Stopping existing service..."
+
+systemctl stop "${name}" 2>/dev/null || true
+
+echo "Installing sysusers config..."
+
+# Group memberships (m lines) may change between runs, the user (u line) may not
+sysusers_identity() {
+    grep '^u ' "${1}" || true
+}
+
+if [ -e "${sysusers_file}" ] || [ -L "${sysusers_file}" ]; then
+    if [ -L "${sysusers_file}" ] || [ ! -f "${sysusers_file}" ] || \
+        [ "$(sysusers_identity "${conf_dir}/${name}.conf")" != "$(sysusers_identity "${sysusers_file}")" ]; then
+        echo "Refusing to replace conflicting sysusers policy: ${sysusers_file}" >&2
+        exit 1
+    fi
+
+    # sysusers only adds memberships, drop the ones no longer configured
+    for group in $(awk -v user="${name}" '$1 == "m" && $2 == user { print $3 }' "${sysusers_file}"); do
+        if ! grep -qx "m ${name} ${group}" "${conf_dir}/${name}.conf"; then
+            echo "Removing ${name} from group ${group}..."
+            gpasswd -d "${name}" "${group}" >/dev/null || true
+        fi
+    done
+
+    install -o root -g root -m 0644 "${conf_dir}/${name}.conf" "${sysusers_file}"
+    systemd-sysusers "${sysusers_file}"
+else
+    if getent passwd "${name}" >/dev/null || getent group "${name}" >/dev/null; then
+        passwd_entry=$(getent passwd "${name}" || true)
+        group_entry=$(getent group "${name}" || true)
+        user_home=$(printf '%s' "${passwd_entry}" | cut -d: -f6)
+        user_shell=$(printf '%s' "${passwd_entry}" | cut -d: -f7)
+
+        if [ -z "${passwd_entry}" ] || [ -z "${group_entry}" ] || [ "${user_home}" != "${path}" ] || \
+            { [ "${user_shell}" != "/sbin/nologin" ] && [ "${user_shell}" != "/usr/sbin/nologin" ]; }; then
+            echo "Refusing to reuse existing user or group: ${name}" >&2
+            exit 1
+        fi
+
+        echo "Adopting service identity created by an older release..."
+    fi
+
+    install -o root -g root -m 0644 "${conf_dir}/${name}.conf" "${sysusers_file}"
+    systemd-sysusers "${sysusers_file}"
+fi
+
+passwd_entry=$(getent passwd "${name}" || true)
+group_entry=$(getent group "${name}" || true)
+user_home=$(printf '%s' "${passwd_entry}" | cut -d: -f6)
+user_shell=$(printf '%s' "${passwd_entry}" | cut -d: -f7)
+
+if [ -z "${passwd_entry}" ] || [ -z "${group_entry}" ] || [ "${user_home}" != "${path}" ] || \
+    { [ "${user_shell}" != "/sbin/nologin" ] && [ "${user_shell}" != "/usr/sbin/nologin" ]; }; then
+    echo "Service identity does not match generated policy: ${name}" >&2
+    exit 1
+fi
+
+echo "Installing unit..."
+
+install -o root -g root -m 0644 "${conf_dir}/${name}.service" "/etc/systemd/system/${name}.service"
+
+if command -v logrotate >/dev/null 2>&1; then
+    echo "Installing logrotate config..."
+
+    install -o root -g root -m 0644 "${conf_dir}/${name}_logs.conf" "/etc/logrotate.d/${name}"
+else
+    echo "Logrotate not found, skipping..."
+fi
+
+if udev_rules_generated; then
+    echo "Removing udev rules..."
+
+    rm -f "${udev_rules}"
+    udevadm control --reload-rules || true
+fi
+
+if notify_env_generated; then
+    echo "Removing failure notifications..."
+
+    rm -f "${notify_env}"
+fi
+
+echo "Setting permissions..."
+
+chown root:root "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" \
+    "${conf_dir}/${name}_logs.conf" "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
+chmod 0755 "${path}"
+chmod 0755 "${conf_dir}"
+chmod 0755 "${path}/${name}"
+chmod 0644 "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" "${conf_dir}/${name}_logs.conf"
+chmod 0700 "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
+
+if [ -e "${path}/releases" ] || [ -L "${path}/releases" ]; then
+    if [ -L "${path}/releases" ] || [ ! -d "${path}/releases" ]; then
+        echo "Refusing unsafe releases directory: ${path}/releases" >&2
+        exit 1
+    fi
+
+    # Releases from mksvc deploy stay root-only
+    chown -R root:root "${path}/releases"
+    chmod 0700 "${path}/releases"
+fi
+
+install -d -o "${name}" -g "${name}" -m 0750 "${path}/logs"
+install -o "${name}" -g "${name}" -m 0640 /dev/null "${path}/logs/${name}.log"
+
+echo "Reloading daemon..."
+
+systemctl daemon-reload
+systemctl enable "${name}"
+
+echo "Setup complete, starting service..."
+
+systemctl restart "${name}"
+
+echo "Waiting for health check..."
+
+if ! wait_healthy; then
+    echo "Service did not become healthy within 30s." >&2
+    echo "Recent log lines:" >&2
+
+    journalctl -u "${name}" -n 20 --no-pager >&2 || true
+    tail -n 20 "${path}/logs/${name}.log" >&2 || true
+
+    restore_previous
+
+    exit 1
+fi
+
+echo "Done."
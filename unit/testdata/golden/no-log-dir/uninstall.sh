@@ -0,0 +1,259 @@
+#!/bin/bash
+
+set -euo pipefail
+
+usage() {
+    echo "Usage: uninstall.sh [--archive FILE] [--purge [--yes]]"
+    echo
+    echo "  --archive FILE  Write logs and writable data to FILE (tar.gz) first"
+    echo "  --purge         Delete logs and writable data after confirmation"
+    echo "  -y, --yes       Do not ask for confirmation"
+}
+
+purge=false
+archive=""
+assume_yes=false
+
+while [ $# -gt 0 ]; do
+    case "${1}" in
+        --purge)
+            purge=true
+            ;;
+        --archive)
+            if [ $# -lt 2 ]; then
+                echo "--archive needs a file name." >&2
+                exit 1
+            fi
+
+            archive="${2}"
+            shift
+            ;;
+        --archive=*)
+            archive="${1#--archive=}"
+            ;;
+        -y|--yes)
+            assume_yes=true
+            ;;
+        -h|--help)
+            usage
+            exit 0
+            ;;
+        *)
+            echo "Unknown option: ${1}" >&2
+            usage >&2
+            exit 1
+            ;;
+    esac
+
+    shift
+done
+
+if [ "${EUID}" -ne 0 ]; then
+    echo "Run this script as root." >&2
+    exit 1
+fi
+
+name="example"
+path="/opt/example"
+generated_sysusers="$(dirname "${BASH_SOURCE[0]}")/${name}.conf"
+installed_sysusers="/etc/sysusers.d/${name}.conf"
+udev_rules="/etc/udev/rules.d/99-${name}.rules"
+notify_env="/etc/mksvc/notify.d/${name}.env"
+notify_unit="/etc/systemd/system/mksvc-notify@.service"
+owns_identity=false
+member_groups=""
+
+# Group memberships (m lines) may change between runs, the user (u line) may not
+sysusers_identity() {
+    grep '^u ' "${1}" || true
+}
+
+# Rules written by hand are never replaced or removed
+udev_rules_generated() {
+    [ -f "${udev_rules}" ] && [ ! -L "${udev_rules}" ] && head -n 1 "${udev_rules}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+notify_env_generated() {
+    [ -f "${notify_env}" ] && [ ! -L "${notify_env}" ] && head -n 1 "${notify_env}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+# The notification unit is shared by all services with notify_on_failure
+notify_unit_generated() {
+    [ -f "${notify_unit}" ] && [ ! -L "${notify_unit}" ] && head -n 1 "${notify_unit}" | grep -q "^# Generated by mksvc\\. "
+}
+
+if [ ! -L "${installed_sysusers}" ] && [ -f "${installed_sysusers}" ] && [ -f "${generated_sysusers}" ] && \
+    [ "$(sysusers_identity "${generated_sysusers}")" = "$(sysusers_identity "${installed_sysusers}")" ]; then
+    passwd_entry=$(getent passwd "${name}" || true)
+    group_entry=$(getent group "${name}" || true)
+    user_home=$(printf '%s' "${passwd_entry}" | cut -d: -f6)
+    user_shell=$(printf '%s' "${passwd_entry}" | cut -d: -f7)
+
+    if [ -n "${passwd_entry}" ] && [ -n "${group_entry}" ] && [ "${user_home}" = "${path}" ] && \
+        { [ "${user_shell}" = "/sbin/nologin" ] || [ "${user_shell}" = "/usr/sbin/nologin" ]; }; then
+        owns_identity=true
+        member_groups=$(awk -v user="${name}" '$1 == "m" && $2 == user { print $3 }' "${installed_sysusers}")
+    fi
+fi
+
+managed_paths=("${path}/${name}.log")
+existing_paths=()
+
+if [ "${purge}" = true ] || [ -n "${archive}" ]; then
+    if [ -L "${path}" ] || [ ! -d "${path}" ]; then
+        echo "Service path must be an existing, real directory: ${path}" >&2
+        exit 1
+    fi
+
+    service_uid=$(id -u "${name}" 2>/dev/null || true)
+
+    if [ -z "${service_uid}" ]; then
+        echo "Service user ${name} does not exist; cannot verify ownership of writable paths." >&2
+        exit 1
+    fi
+
+    for target in "${managed_paths[@]}"; do
+        if [ -L "${target}" ]; then
+            echo "Refusing symlinked managed path: ${target}" >&2
+            exit 1
+        fi
+
+        if [ ! -e "${target}" ]; then
+            continue
+        fi
+
+        if [ "$(stat -c %u "${target}")" != "${service_uid}" ]; then
+            echo "Refusing managed path not owned by ${name}: ${target}" >&2
+            exit 1
+        fi
+
+        existing_paths+=("${target}")
+    done
+fi
+
+if [ -n "${archive}" ]; then
+    archive=$(realpath -m "${archive}")
+
+    if [ -e "${archive}" ] || [ -L "${archive}" ]; then
+        echo "Refusing to overwrite existing archive: ${archive}" >&2
+        exit 1
+    fi
+
+    if [ ! -d "$(dirname "${archive}")" ]; then
+        echo "Archive directory does not exist: $(dirname "${archive}")" >&2
+        exit 1
+    fi
+
+    for target in "${managed_paths[@]}"; do
+        if [ "${archive}" = "${target}" ] || [[ "${archive}" == "${target}/"* ]]; then
+            echo "Archive must not be written inside ${target}." >&2
+            exit 1
+        fi
+    done
+fi
+
+if [ "${purge}" = true ] && [ "${assume_yes}" != true ]; then
+    if [ ! -t 0 ]; then
+        echo "Refusing to purge without confirmation; pass --yes." >&2
+        exit 1
+    fi
+
+    echo "This permanently deletes:"
+    printf '  %s\n' "${existing_paths[@]}"
+
+    read -r -p "Type the service name to confirm: " answer
+
+    if [ "${answer}" != "${name}" ]; then
+        echo "Aborted." >&2
+        exit 1
+    fi
+fi
+
+echo "Stopping service..."
+systemctl stop "${name}" 2>/dev/null || true
+
+if [ -n "${archive}" ] && [ "${#existing_paths[@]}" -eq 0 ]; then
+    echo "No logs or data to archive."
+elif [ -n "${archive}" ]; then
+    echo "Archiving logs and data to ${archive}..."
+
+    relative_paths=()
+
+    for target in "${existing_paths[@]}"; do
+        relative_paths+=("${target#"${path}"/}")
+    done
+
+    (umask 077 && tar --numeric-owner -czf "${archive}" -C "${path}" -- "${relative_paths[@]}")
+fi
+
+if [ "${purge}" = true ]; then
+    echo "Purging logs and data..."
+
+    for target in "${existing_paths[@]}"; do
+        rm -rf -- "${target}"
+    done
+fi
+
+echo "Disabling service..."
+systemctl disable "${name}" 2>/dev/null || true
+
+echo "Removing unit file..."
+rm -f "/etc/systemd/system/${name}.service"
+
+echo "Removing sysusers config..."
+
+if [ "${owns_identity}" = true ]; then
+    rm -f "${installed_sysusers}"
+else
+    echo "Installed sysusers policy does not match; leaving it unchanged."
+fi
+
+if [ -f "/etc/logrotate.d/${name}" ]; then
+    echo "Removing logrotate config..."
+    rm -f "/etc/logrotate.d/${name}"
+fi
+
+if udev_rules_generated; then
+    echo "Removing udev rules..."
+    rm -f "${udev_rules}"
+    udevadm control --reload-rules || true
+fi
+
+if notify_env_generated; then
+    echo "Removing failure notifications..."
+    rm -f "${notify_env}"
+fi
+
+if notify_unit_generated && ! compgen -G "$(dirname "${notify_env}")/*.env" >/dev/null; then
+    echo "Removing unused notification unit..."
+    rm -f "${notify_unit}"
+fi
+
+echo "Reloading daemon..."
+systemctl daemon-reload
+systemctl reset-failed "${name}" 2>/dev/null || true
+
+if [ "${owns_identity}" = true ]; then
+    echo "Removing mksvc user and group..."
+
+    for group in ${member_groups}; do
+        gpasswd -d "${name}" "${group}" >/dev/null 2>&1 || true
+    done
+
+    if id "${name}" &>/dev/null; then
+        userdel "${name}"
+    fi
+
+    if getent group "${name}" &>/dev/null; then
+        groupdel "${name}"
+    fi
+
+else
+    echo "Identity ownership could not be verified, leaving user and group unchanged."
+fi
+
+if [ "${purge}" = true ]; then
+    echo "Uninstall complete. Logs and data were purged; application files were left in place."
+else
+    echo "Uninstall complete. Application files were left in place."
+fi
@@ -0,0 +1,255 @@
+#!/bin/bash
+
+set -euo pipefail
+
+if [ "${EUID}" -ne 0 ]; then
+    echo "Run this script as root." >&2
+    exit 1
+fi
+
+name="example"
+path="/opt/example"
+conf_dir="${path}/conf"
+backup_base="/var/backups/mksvc"
+backup_root="${backup_base}/${name}"
+
+if [ -L "${path}" ] || [ ! -d "${path}" ]; then
+    echo "Service path must be an existing, real directory: ${path}" >&2
+    exit 1
+fi
+
+script_root=$(realpath "$(dirname "${BASH_SOURCE[0]}")/..")
+service_root=$(realpath "${path}")
+
+if [ "${script_root}" != "${service_root}" ]; then
+    echo "Run the setup script from ${path}/conf." >&2
+    exit 1
+fi
+
+for file in "${conf_dir}/${name}.initd" "${conf_dir}/${name}.confd" "${conf_dir}/${name}_logs.conf"; do
+    if [ -L "${file}" ] || [ ! -f "${file}" ]; then
+        echo "Missing or unsafe generated file: ${file}" >&2
+        exit 1
+    fi
+done
+
+if [ -L "${path}/${name}" ] || [ ! -f "${path}/${name}" ]; then
+    echo "Missing or unsafe service executable: ${path}/${name}" >&2
+    exit 1
+fi
+
+for tool in rc-service rc-update supervise-daemon adduser addgroup getent; do
+    if ! command -v "${tool}" >/dev/null 2>&1; then
+        echo "This script needs ${tool}; it is meant for OpenRC systems such as Alpine." >&2
+        exit 1
+    fi
+done
+
+for group in ssl-cert; do
+    if ! getent group "${group}" >/dev/null; then
+        echo "Supplementary group does not exist: ${group}" >&2
+        exit 1
+    fi
+done
+
+health_check() {
+    timeout 5 su -s /bin/sh -c '/opt/example/example --check' "${name}"
+}
+
+wait_healthy() {
+    local deadline=$((SECONDS + 30))
+
+    until rc-service "${name}" status >/dev/null 2>&1 && health_check >/dev/null 2>&1; do
+        if [ "${SECONDS}" -ge "${deadline}" ]; then
+            return 1
+        fi
+
+        sleep 1
+    done
+}
+
+# Puts back every file listed in the backup manifest (the one mksvc restore
+# reads) and removes the ones that did not exist before
+restore_backup_files() {
+    local kind file stored
+
+    while read -r kind file stored; do
+        case "${kind}" in
+            file)
+                install -o root -g root -m "$(stat -c '%a' "${backup_dir}/${stored}")" "${backup_dir}/${stored}" "${file}"
+                ;;
+            absent)
+                rm -f "${file}"
+                ;;
+        esac
+    done < "${manifest}"
+}
+
+restore_previous() {
+    echo "Restoring previously installed files..." >&2
+
+    if [ ! -f "${backup_dir}/unit" ]; then
+        rc-service "${name}" stop 2>/dev/null || true
+        rc-update del "${name}" default 2>/dev/null || true
+    fi
+
+    restore_backup_files
+
+    if [ -f "${backup_dir}/unit" ]; then
+        rc-service "${name}" restart || true
+    fi
+}
+
+echo "Backing up installed files..."
+
+for dir in "${backup_base}" "${backup_root}"; do
+    if [ -L "${dir}" ] || { [ -e "${dir}" ] && [ ! -d "${dir}" ]; }; then
+        echo "Refusing unsafe backup directory: ${dir}" >&2
+        exit 1
+    fi
+done
+
+install -d -o root -g root -m 0700 "${backup_base}" "${backup_root}"
+
+backup_dir=$(mktemp -d "${backup_root}/$(date -u +%Y%m%dT%H%M%SZ)-XXXXXX")
+manifest="${backup_dir}/manifest"
+
+echo "# mksvc backup of ${name}" > "${manifest}"
+
+backup_file() {
+    if [ -f "${1}" ] && [ ! -L "${1}" ]; then
+        cp "${1}" "${backup_dir}/${2}"
+        echo "file ${1} ${2}" >> "${manifest}"
+    else
+        echo "absent ${1}" >> "${manifest}"
+    fi
+}
+
+backup_file "/etc/init.d/${name}" unit
+backup_file "/etc/conf.d/${name}" confd
+backup_file "/etc/logrotate.d/${name}" logrotate
+
+for target in "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.initd" "${conf_dir}/${name}.confd" \
+    "${conf_dir}/${name}_logs.conf" "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml" \
+    "${path}/logs" "${path}/logs/${name}.log" "${path}/${name}.log" "${path}/data" \
+    "${path}/releases"; do
+    if [ -e "${target}" ] && [ ! -L "${target}" ]; then
+        stat -c 'stat %n %a %u %g' "${target}" >> "${manifest}"
+    fi
+done
+
+# Keep the 10 newest backups
+find "${backup_root}" -mindepth 1 -maxdepth 1 -type d | sort | head -n -10 | while read -r old; do
+    rm -rf "${old}"
+done
+
+echo "Backup written to ${backup_dir}"
+
+echo "Stopping existing service..."
+
+if [ -f "/etc/init.d/${name}" ]; then
+    rc-service "${name}" stop 2>/dev/null || true
+fi
+
+echo "Creating service user..."
+
+if getent passwd "${name}" >/dev/null || getent group "${name}" >/dev/null; then
+    passwd_entry=$(getent passwd "${name}" || true)
+    group_entry=$(getent group "${name}" || true)
+    user_home=$(printf '%s' "${passwd_entry}" | cut -d: -f6)
+    user_shell=$(printf '%s' "${passwd_entry}" | cut -d: -f7)
+
+    if [ -z "${passwd_entry}" ] || [ -z "${group_entry}" ] || [ "${user_home}" != "${path}" ] || \
+        { [ "${user_shell}" != "/sbin/nologin" ] && [ "${user_shell}" != "/usr/sbin/nologin" ]; }; then
+        echo "Refusing to reuse existing user or group: ${name}" >&2
+        exit 1
+    fi
+else
+    addgroup -S "${name}"
+    adduser -S -D -H -h "${path}" -s /sbin/nologin -G "${name}" -g "Example Service" "${name}"
+fi
+
+# addgroup only adds memberships, drop the ones no longer configured
+if [ -f "/etc/conf.d/${name}" ] && [ ! -L "/etc/conf.d/${name}" ]; then
+    for group in $(sed -n 's/^mksvc_groups="\([a-z0-9_ -]*\)"$/\1/p' "/etc/conf.d/${name}"); do
+        case " ssl-cert " in
+            *" ${group} "*) ;;
+            *)
+                if [ "${group}" != "${name}" ]; then
+                    echo "Removing ${name} from group ${group}..."
+                    delgroup "${name}" "${group}" 2>/dev/null || true
+                fi
+                ;;
+        esac
+    done
+fi
+
+echo "Adding supplementary groups..."
+
+for group in ssl-cert; do
+    if ! id -nG "${name}" | tr ' ' '\n' | grep -qx "${group}"; then
+        addgroup "${name}" "${group}"
+    fi
+done
+
+echo "Installing init script..."
+
+install -o root -g root -m 0755 "${conf_dir}/${name}.initd" "/etc/init.d/${name}"
+install -o root -g root -m 0644 "${conf_dir}/${name}.confd" "/etc/conf.d/${name}"
+
+if command -v logrotate >/dev/null 2>&1; then
+    echo "Installing logrotate config..."
+
+    install -o root -g root -m 0644 "${conf_dir}/${name}_logs.conf" "/etc/logrotate.d/${name}"
+else
+    echo "Logrotate not found, skipping..."
+fi
+
+echo "Setting permissions..."
+
+chown root:root "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.initd" "${conf_dir}/${name}.confd" \
+    "${conf_dir}/${name}_logs.conf" "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
+chmod 0755 "${path}"
+chmod 0755 "${conf_dir}"
+chmod 0755 "${path}/${name}"
+chmod 0644 "${conf_dir}/${name}.initd" "${conf_dir}/${name}.confd" "${conf_dir}/${name}_logs.conf"
+chmod 0700 "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
+
+if [ -e "${path}/releases" ] || [ -L "${path}/releases" ]; then
+    if [ -L "${path}/releases" ] || [ ! -d "${path}/releases" ]; then
+        echo "Refusing unsafe releases directory: ${path}/releases" >&2
+        exit 1
+    fi
+
+    # Releases from mksvc deploy stay root-only
+    chown -R root:root "${path}/releases"
+    chmod 0700 "${path}/releases"
+fi
+
+install -d -o "${name}" -g "${name}" -m 0750 "${path}/logs"
+install -o "${name}" -g "${name}" -m 0640 /dev/null "${path}/logs/${name}.log"
+
+install -d -o "${name}" -g "${name}" -m 0750 "${path}/data"
+
+echo "Enabling service..."
+
+rc-update add "${name}" default
+
+echo "Setup complete, starting service..."
+
+rc-service "${name}" restart
+
+echo "Waiting for health check..."
+
+if ! wait_healthy; then
+    echo "Service did not become healthy within 30s." >&2
+    echo "Recent log lines:" >&2
+
+    tail -n 20 "${path}/logs/${name}.log" >&2 || true
+
+    restore_previous
+
+    exit 1
+fi
+
+echo "Done."
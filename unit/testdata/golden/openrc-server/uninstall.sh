@@ -0,0 +1,205 @@
+#!/bin/bash
+
+set -euo pipefail
+
+usage() {
+    echo "Usage: uninstall.sh [--archive FILE] [--purge [--yes]]"
+    echo
+    echo "  --archive FILE  Write logs and writable data to FILE (tar.gz) first"
+    echo "  --purge         Delete logs and writable data after confirmation"
+    echo "  -y, --yes       Do not ask for confirmation"
+}
+
+purge=false
+archive=""
+assume_yes=false
+
+while [ $# -gt 0 ]; do
+    case "${1}" in
+        --purge)
+            purge=true
+            ;;
+        --archive)
+            if [ $# -lt 2 ]; then
+                echo "--archive needs a file name." >&2
+                exit 1
+            fi
+
+            archive="${2}"
+            shift
+            ;;
+        --archive=*)
+            archive="${1#--archive=}"
+            ;;
+        -y|--yes)
+            assume_yes=true
+            ;;
+        -h|--help)
+            usage
+            exit 0
+            ;;
+        *)
+            echo "Unknown option: ${1}" >&2
+            usage >&2
+            exit 1
+            ;;
+    esac
+
+    shift
+done
+
+if [ "${EUID}" -ne 0 ]; then
+    echo "Run this script as root." >&2
+    exit 1
+fi
+
+name="example"
+path="/opt/example"
+owns_identity=false
+
+# setup.sh creates the user with this exact home, shell and comment
+passwd_entry=$(getent passwd "${name}" || true)
+group_entry=$(getent group "${name}" || true)
+user_comment=$(printf '%s' "${passwd_entry}" | cut -d: -f5)
+user_home=$(printf '%s' "${passwd_entry}" | cut -d: -f6)
+user_shell=$(printf '%s' "${passwd_entry}" | cut -d: -f7)
+
+if [ -n "${passwd_entry}" ] && [ -n "${group_entry}" ] && [ "${user_home}" = "${path}" ] && \
+    [ "${user_comment}" = "Example Service" ] && \
+    { [ "${user_shell}" = "/sbin/nologin" ] || [ "${user_shell}" = "/usr/sbin/nologin" ]; }; then
+    owns_identity=true
+fi
+
+managed_paths=("${path}/logs" "${path}/data")
+existing_paths=()
+
+if [ "${purge}" = true ] || [ -n "${archive}" ]; then
+    if [ -L "${path}" ] || [ ! -d "${path}" ]; then
+        echo "Service path must be an existing, real directory: ${path}" >&2
+        exit 1
+    fi
+
+    service_uid=$(id -u "${name}" 2>/dev/null || true)
+
+    if [ -z "${service_uid}" ]; then
+        echo "Service user ${name} does not exist; cannot verify ownership of writable paths." >&2
+        exit 1
+    fi
+
+    for target in "${managed_paths[@]}"; do
+        if [ -L "${target}" ]; then
+            echo "Refusing symlinked managed path: ${target}" >&2
+            exit 1
+        fi
+
+        if [ ! -e "${target}" ]; then
+            continue
+        fi
+
+        if [ "$(stat -c %u "${target}")" != "${service_uid}" ]; then
+            echo "Refusing managed path not owned by ${name}: ${target}" >&2
+            exit 1
+        fi
+
+        existing_paths+=("${target}")
+    done
+fi
+
+if [ -n "${archive}" ]; then
+    archive=$(realpath -m "${archive}")
+
+    if [ -e "${archive}" ] || [ -L "${archive}" ]; then
+        echo "Refusing to overwrite existing archive: ${archive}" >&2
+        exit 1
+    fi
+
+    if [ ! -d "$(dirname "${archive}")" ]; then
+        echo "Archive directory does not exist: $(dirname "${archive}")" >&2
+        exit 1
+    fi
+
+    for target in "${managed_paths[@]}"; do
+        if [ "${archive}" = "${target}" ] || [[ "${archive}" == "${target}/"* ]]; then
+            echo "Archive must not be written inside ${target}." >&2
+            exit 1
+        fi
+    done
+fi
+
+if [ "${purge}" = true ] && [ "${assume_yes}" != true ]; then
+    if [ ! -t 0 ]; then
+        echo "Refusing to purge without confirmation; pass --yes." >&2
+        exit 1
+    fi
+
+    echo "This permanently deletes:"
+    printf '  %s\n' "${existing_paths[@]}"
+
+    read -r -p "Type the service name to confirm: " answer
+
+    if [ "${answer}" != "${name}" ]; then
+        echo "Aborted." >&2
+        exit 1
+    fi
+fi
+
+echo "Stopping service..."
+rc-service "${name}" stop 2>/dev/null || true
+
+if [ -n "${archive}" ] && [ "${#existing_paths[@]}" -eq 0 ]; then
+    echo "No logs or data to archive."
+elif [ -n "${archive}" ]; then
+    echo "Archiving logs and data to ${archive}..."
+
+    relative_paths=()
+
+    for target in "${existing_paths[@]}"; do
+        relative_paths+=("${target#"${path}"/}")
+    done
+
+    (umask 077 && tar --numeric-owner -czf "${archive}" -C "${path}" -- "${relative_paths[@]}")
+fi
+
+if [ "${purge}" = true ]; then
+    echo "Purging logs and data..."
+
+    for target in "${existing_paths[@]}"; do
+        rm -rf -- "${target}"
+    done
+fi
+
+echo "Disabling service..."
+rc-update del "${name}" default 2>/dev/null || true
+
+echo "Removing init script..."
+rm -f "/etc/init.d/${name}" "/etc/conf.d/${name}"
+
+if [ -f "/etc/logrotate.d/${name}" ]; then
+    echo "Removing logrotate config..."
+    rm -f "/etc/logrotate.d/${name}"
+fi
+
+if [ "${owns_identity}" = true ]; then
+    echo "Removing mksvc user and group..."
+
+    for group in ssl-cert; do
+        delgroup "${name}" "${group}" 2>/dev/null || true
+    done
+
+    if id "${name}" &>/dev/null; then
+        deluser "${name}"
+    fi
+
+    if getent group "${name}" &>/dev/null; then
+        delgroup "${name}"
+    fi
+
+else
+    echo "Identity ownership could not be verified, leaving user and group unchanged."
+fi
+
+if [ "${purge}" = true ]; then
+    echo "Uninstall complete. Logs and data were purged; application files were left in place."
+else
+    echo "Uninstall complete. Application files were left in place."
+fi
@@ -0,0 +1,145 @@
+#!/bin/bash
+
+set -euo pipefail
+
+if [ "${EUID}" -eq 0 ]; then
+    echo "Run this script as the user owning the service, not as root." >&2
+    exit 1
+fi
+
+name="example"
+path="/home/dev/example"
+conf_dir="${path}/conf"
+unit_dir="${XDG_CONFIG_HOME:-${HOME}/.config}/systemd/user"
+unit_file="${unit_dir}/${name}.service"
+
+if [ -L "${path}" ] || [ ! -d "${path}" ]; then
+    echo "Service path must be an existing, real directory: ${path}" >&2
+    exit 1
+fi
+
+if [ "$(stat -c %u "${path}")" != "${EUID}" ]; then
+    echo "Service path must be owned by you: ${path}" >&2
+    exit 1
+fi
+
+script_root=$(realpath "$(dirname "${BASH_SOURCE[0]}")/..")
+service_root=$(realpath "${path}")
+
+if [ "${script_root}" != "${service_root}" ]; then
+    echo "Run the setup script from ${path}/conf." >&2
+    exit 1
+fi
+
+if [ -L "${conf_dir}/${name}.service" ] || [ ! -f "${conf_dir}/${name}.service" ]; then
+    echo "Missing or unsafe generated file: ${conf_dir}/${name}.service" >&2
+    exit 1
+fi
+
+if [ -L "${path}/${name}" ] || [ ! -f "${path}/${name}" ]; then
+    echo "Missing or unsafe service executable: ${path}/${name}" >&2
+    exit 1
+fi
+
+health_check() {
+    timeout 5 /bin/sh -c '/home/dev/example/example --check'
+}
+
+wait_healthy() {
+    local deadline=$((SECONDS + 30))
+
+    until systemctl --user is-active --quiet "${name}" && health_check >/dev/null 2>&1; do
+        if [ "${SECONDS}" -ge "${deadline}" ]; then
+            return 1
+        fi
+
+        sleep 1
+    done
+}
+
+previous_unit=""
+
+if [ -f "${unit_file}" ]; then
+    previous_unit=$(mktemp)
+    trap 'rm -f "${previous_unit}"' EXIT
+
+    cp "${unit_file}" "${previous_unit}"
+fi
+
+restore_previous() {
+    echo "Restoring previous unit file..." >&2
+
+    if [ -n "${previous_unit}" ]; then
+        install -m 0644 "${previous_unit}" "${unit_file}"
+        systemctl --user daemon-reload
+        systemctl --user restart "${name}" || true
+    else
+        systemctl --user disable --now "${name}" 2>/dev/null || true
+        rm -f "${unit_file}"
+        systemctl --user daemon-reload
+    fi
+}
+
+echo "Stopping existing service..."
+
+systemctl --user stop "${name}" 2>/dev/null || true
+
+echo "Installing unit..."
+
+install -d -m 0755 "${unit_dir}"
+install -m 0644 "${conf_dir}/${name}.service" "${unit_file}"
+
+echo "Setting permissions..."
+
+chmod 0755 "${path}"
+chmod 0755 "${conf_dir}"
+chmod 0755 "${path}/${name}"
+chmod 0644 "${conf_dir}/${name}.service"
+chmod 0700 "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
+
+if [ -e "${path}/releases" ] || [ -L "${path}/releases" ]; then
+    if [ -L "${path}/releases" ] || [ ! -d "${path}/releases" ]; then
+        echo "Refusing unsafe releases directory: ${path}/releases" >&2
+        exit 1
+    fi
+
+    chmod 0700 "${path}/releases"
+fi
+
+install -d -m 0750 "${path}/logs"
+log_file="${path}/logs/${name}.log"
+
+if [ -L "${log_file}" ]; then
+    echo "Refusing symlinked log file: ${log_file}" >&2
+    exit 1
+fi
+
+touch "${log_file}"
+chmod 0640 "${log_file}"
+
+install -d -m 0750 "${path}/data"
+
+echo "Reloading daemon..."
+
+systemctl --user daemon-reload
+systemctl --user enable "${name}"
+
+echo "Setup complete, starting service..."
+
+systemctl --user restart "${name}"
+
+echo "Waiting for health check..."
+
+if ! wait_healthy; then
+    echo "Service did not become healthy within 30s." >&2
+    echo "Recent log lines:" >&2
+
+    journalctl --user -u "${name}" -n 20 --no-pager >&2 || true
+    tail -n 20 "${log_file}" >&2 || true
+
+    restore_previous
+
+    exit 1
+fi
+
+echo "Done. Run 'loginctl enable-linger' to keep the service running after you log out."
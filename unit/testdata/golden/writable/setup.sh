@@ -7,10 +7,15 @@ if [ "${EUID}" -ne 0 ]; then
     exit 1
 fi
 
-name="{{ .Name }}"
-path="{{ .Path }}"
+name="example"
+path="/opt/example"
 conf_dir="${path}/conf"
 sysusers_file="/etc/sysusers.d/${name}.conf"
+udev_rules="/etc/udev/rules.d/99-${name}.rules"
+notify_env="/etc/mksvc/notify.d/${name}.env"
+notify_unit="/etc/systemd/system/mksvc-notify@.service"
+backup_base="/var/backups/mksvc"
+backup_root="${backup_base}/${name}"
 
 if [ -L "${path}" ] || [ ! -d "${path}" ]; then
     echo "Service path must be an existing, real directory: ${path}" >&2
@@ -37,18 +42,94 @@ if [ -L "${path}/${name}" ] || [ ! -f "${path}/${name}" ]; then
     exit 1
 fi
 
+# Rules written by hand are never replaced or removed
+udev_rules_generated() {
+    [ -f "${udev_rules}" ] && [ ! -L "${udev_rules}" ] && head -n 1 "${udev_rules}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+notify_env_generated() {
+    [ -f "${notify_env}" ] && [ ! -L "${notify_env}" ] && head -n 1 "${notify_env}" | grep -qx "# Generated by mksvc for ${name}"
+}
+
+# The notification unit is shared by all services with notify_on_failure
+notify_unit_generated() {
+    [ -f "${notify_unit}" ] && [ ! -L "${notify_unit}" ] && head -n 1 "${notify_unit}" | grep -q "^# Generated by mksvc\\. "
+}
+
+echo "Backing up installed files..."
+
+for dir in "${backup_base}" "${backup_root}"; do
+    if [ -L "${dir}" ] || { [ -e "${dir}" ] && [ ! -d "${dir}" ]; }; then
+        echo "Refusing unsafe backup directory: ${dir}" >&2
+        exit 1
+    fi
+done
+
+install -d -o root -g root -m 0700 "${backup_base}" "${backup_root}"
+
+backup_dir=$(mktemp -d "${backup_root}/$(date -u +%Y%m%dT%H%M%SZ)-XXXXXX")
+manifest="${backup_dir}/manifest"
+
+echo "# mksvc backup of ${name}" > "${manifest}"
+
+backup_file() {
+    if [ -f "${1}" ] && [ ! -L "${1}" ]; then
+        cp "${1}" "${backup_dir}/${2}"
+        echo "file ${1} ${2}" >> "${manifest}"
+    else
+        echo "absent ${1}" >> "${manifest}"
+    fi
+}
+
+backup_file "/etc/systemd/system/${name}.service" unit
+backup_file "${sysusers_file}" sysusers
+backup_file "/etc/logrotate.d/${name}" logrotate
+backup_file "${udev_rules}" udev
+backup_file "${notify_env}" notify
+
+for target in "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" \
+    "${conf_dir}/${name}_logs.conf" "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml" \
+    "${path}/logs" "${path}/logs/${name}.log" "${path}/${name}.log" "${path}/data" \
+    "${path}/config.yml" "${path}/releases"; do
+    if [ -e "${target}" ] && [ ! -L "${target}" ]; then
+        stat -c 'stat %n %a %u %g' "${target}" >> "${manifest}"
+    fi
+done
+
+# Keep the 10 newest backups
+find "${backup_root}" -mindepth 1 -maxdepth 1 -type d -printf '%f\n' | sort | head -n -10 | while read -r old; do
+    rm -rf "${backup_root:?}/${old}"
+done
+
+echo "Backup written to ${backup_dir}"
+
 echo "Stopping existing service..."
 
 systemctl stop "${name}" 2>/dev/null || true
 
 echo "Installing sysusers config..."
 
+# Group memberships (m lines) may change between runs, the user (u line) may not
+sysusers_identity() {
+    grep '^u ' "${1}" || true
+}
+
 if [ -e "${sysusers_file}" ] || [ -L "${sysusers_file}" ]; then
-    if [ -L "${sysusers_file}" ] || [ ! -f "${sysusers_file}" ] || ! cmp -s "${conf_dir}/${name}.conf" "${sysusers_file}"; then
+    if [ -L "${sysusers_file}" ] || [ ! -f "${sysusers_file}" ] || \
+        [ "$(sysusers_identity "${conf_dir}/${name}.conf")" != "$(sysusers_identity "${sysusers_file}")" ]; then
         echo "Refusing to replace conflicting sysusers policy: ${sysusers_file}" >&2
         exit 1
     fi
 
+    # sysusers only adds memberships, drop the ones no longer configured
+    for group in $(awk -v user="${name}" '$1 == "m" && $2 == user { print $3 }' "${sysusers_file}"); do
+        if ! grep -qx "m ${name} ${group}" "${conf_dir}/${name}.conf"; then
+            echo "Removing ${name} from group ${group}..."
+            gpasswd -d "${name}" "${group}" >/dev/null || true
+        fi
+    done
+
+    install -o root -g root -m 0644 "${conf_dir}/${name}.conf" "${sysusers_file}"
     systemd-sysusers "${sysusers_file}"
 else
     if getent passwd "${name}" >/dev/null || getent group "${name}" >/dev/null; then
@@ -93,6 +174,19 @@ else
     echo "Logrotate not found, skipping..."
 fi
 
+if udev_rules_generated; then
+    echo "Removing udev rules..."
+
+    rm -f "${udev_rules}"
+    udevadm control --reload-rules || true
+fi
+
+if notify_env_generated; then
+    echo "Removing failure notifications..."
+
+    rm -f "${notify_env}"
+fi
+
 echo "Setting permissions..."
 
 chown root:root "${path}" "${conf_dir}" "${path}/${name}" "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" \
@@ -103,27 +197,23 @@ chmod 0755 "${path}/${name}"
 chmod 0644 "${conf_dir}/${name}.conf" "${conf_dir}/${name}.service" "${conf_dir}/${name}_logs.conf"
 chmod 0700 "${conf_dir}/setup.sh" "${conf_dir}/uninstall.sh" "${conf_dir}/svc.yml"
 
-{{- if .Devices }}
-# Hardware access normally also needs a udev rule assigning the device to this service user.
-# Example: /etc/udev/rules.d/99-{{ .Name }}.rules
-# SUBSYSTEM=="usb", ATTRS{idVendor}=="XXXX", OWNER="{{ .Name }}"
-{{- end }}
+if [ -e "${path}/releases" ] || [ -L "${path}/releases" ]; then
+    if [ -L "${path}/releases" ] || [ ! -d "${path}/releases" ]; then
+        echo "Refusing unsafe releases directory: ${path}/releases" >&2
+        exit 1
+    fi
 
-{{- if .SeparateLogDir }}
+    # Releases from mksvc deploy stay root-only
+    chown -R root:root "${path}/releases"
+    chmod 0700 "${path}/releases"
+fi
 
 install -d -o "${name}" -g "${name}" -m 0750 "${path}/logs"
 install -o "${name}" -g "${name}" -m 0640 /dev/null "${path}/logs/${name}.log"
-{{- else }}
-
-install -o "${name}" -g "${name}" -m 0640 /dev/null "${path}/${name}.log"
-{{- end }}
-{{- if .WritableFiles }}
 
 install -d -o "${name}" -g "${name}" -m 0750 "${path}/data"
-{{- end }}
-{{- if .WritableConfig }}
 
-config_file="${path}/{{ .ConfigFile }}"
+config_file="${path}/config.yml"
 if [ -L "${config_file}" ] || { [ -e "${config_file}" ] && [ ! -f "${config_file}" ]; }; then
     echo "Refusing unsafe writable config file: ${config_file}" >&2
     exit 1
@@ -140,7 +230,6 @@ else
     chown "${name}:${name}" "${config_file}"
     chmod 0600 "${config_file}"
 fi
-{{- end }}
 
 echo "Reloading daemon..."
 
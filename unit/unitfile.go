@@ -0,0 +1,258 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var unitKeyRgx = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// UnitFile is a parsed systemd unit file. Sections and entries keep their
+// file order and repeated sections stay separate, so the file can be written
+// back without reordering. Values are kept verbatim: systemd has no inline
+// comments and quoting is interpreted per directive.
+type UnitFile struct {
+	Sections []*UnitSection
+}
+
+type UnitSection struct {
+	Name    string
+	Line    int
+	Entries []UnitEntry
+}
+
+type UnitEntry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// UnitChange is a directive whose effective values differ between two units.
+// Old is empty for added directives and New is empty for removed ones.
+type UnitChange struct {
+	Section string
+	Key     string
+	Old     []string
+	New     []string
+}
+
+func ParseUnitFile(data []byte) (*UnitFile, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var (
+		file    UnitFile
+		current *UnitSection
+
+		pending     strings.Builder
+		pendingLine int
+	)
+
+	lines := strings.Split(string(data), "\n")
+
+	for i, raw := range lines {
+		number := i + 1
+
+		line := strings.TrimSpace(strings.TrimSuffix(raw, "\r"))
+
+		if pendingLine != 0 {
+			// Comments inside a continuation are skipped, like systemd does
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+				continue
+			}
+
+			if before, ok := strings.CutSuffix(line, "\\"); ok {
+				pending.WriteString(strings.TrimSpace(before))
+				pending.WriteString(" ")
+
+				continue
+			}
+
+			pending.WriteString(line)
+
+			line = pending.String()
+			number = pendingLine
+
+			pending.Reset()
+
+			pendingLine = 0
+		} else if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		} else if before, ok := strings.CutSuffix(line, "\\"); ok {
+			pending.WriteString(strings.TrimSpace(before))
+			pending.WriteString(" ")
+
+			pendingLine = number
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutSuffix(line[1:], "]")
+			if !ok || name == "" || strings.ContainsAny(name, "[]") {
+				return nil, fmt.Errorf("line %d: invalid section header %q", number, line)
+			}
+
+			current = &UnitSection{
+				Name: name,
+				Line: number,
+			}
+
+			file.Sections = append(file.Sections, current)
+
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: assignment outside of a section", number)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '=' in %q", number, line)
+		}
+
+		key = strings.TrimSpace(key)
+
+		if !unitKeyRgx.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid directive name %q", number, key)
+		}
+
+		current.Entries = append(current.Entries, UnitEntry{
+			Key:   key,
+			Value: strings.TrimSpace(value),
+			Line:  number,
+		})
+	}
+
+	if pendingLine != 0 {
+		return nil, fmt.Errorf("line %d: unterminated line continuation", pendingLine)
+	}
+
+	return &file, nil
+}
+
+// Entries returns the entries of every section called name, in file order.
+func (f *UnitFile) Entries(section string) []UnitEntry {
+	var entries []UnitEntry
+
+	for _, s := range f.Sections {
+		if s.Name == section {
+			entries = append(entries, s.Entries...)
+		}
+	}
+
+	return entries
+}
+
+// Values returns the effective values of a directive. An empty assignment
+// resets the list, so "Environment=" drops everything assigned before it.
+func (f *UnitFile) Values(section, key string) []string {
+	var values []string
+
+	for _, entry := range f.Entries(section) {
+		if entry.Key != key {
+			continue
+		}
+
+		if entry.Value == "" {
+			values = values[:0]
+
+			continue
+		}
+
+		values = append(values, entry.Value)
+	}
+
+	return values
+}
+
+// Keys returns the distinct directive names of a section in order of first
+// appearance.
+func (f *UnitFile) Keys(section string) []string {
+	var keys []string
+
+	for _, entry := range f.Entries(section) {
+		if !slices.Contains(keys, entry.Key) {
+			keys = append(keys, entry.Key)
+		}
+	}
+
+	return keys
+}
+
+// SectionNames returns the distinct section names in order of first
+// appearance.
+func (f *UnitFile) SectionNames() []string {
+	var names []string
+
+	for _, s := range f.Sections {
+		if !slices.Contains(names, s.Name) {
+			names = append(names, s.Name)
+		}
+	}
+
+	return names
+}
+
+func (f *UnitFile) Bytes() []byte {
+	var buf bytes.Buffer
+
+	for i, s := range f.Sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString("[" + s.Name + "]\n")
+
+		for _, entry := range s.Entries {
+			buf.WriteString(entry.Key + "=" + entry.Value + "\n")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// DiffUnits compares the effective directive values of two units, ordered by
+// section and first appearance in b, then a.
+func DiffUnits(a, b *UnitFile) []UnitChange {
+	var changes []UnitChange
+
+	sections := b.SectionNames()
+
+	for _, name := range a.SectionNames() {
+		if !slices.Contains(sections, name) {
+			sections = append(sections, name)
+		}
+	}
+
+	for _, section := range sections {
+		keys := b.Keys(section)
+
+		for _, key := range a.Keys(section) {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+
+		for _, key := range keys {
+			before := a.Values(section, key)
+			after := b.Values(section, key)
+
+			if slices.Equal(before, after) {
+				continue
+			}
+
+			changes = append(changes, UnitChange{
+				Section: section,
+				Key:     key,
+				Old:     before,
+				New:     after,
+			})
+		}
+	}
+
+	return changes
+}
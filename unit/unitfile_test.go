@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseUnitFile(t *testing.T) {
+	data := "\xef\xbb\xbf# leading comment\r\n" +
+		"[Unit]\r\n" +
+		"After=network.target\n" +
+		"\n" +
+		"[Service]\n" +
+		"Environment=A=1 # not a comment\n" +
+		"ExecStart=/opt/app/app \\\n" +
+		"  --flag \\\n" +
+		"# skipped inside a continuation\n" +
+		"  --other\n" +
+		"  Environment = B=2  \n" +
+		"\n" +
+		"[Unit]\n" +
+		"After=db.service\n" +
+		"[Service]\n" +
+		"Environment=\n" +
+		"Environment=\"C=3 D=4\"\n"
+
+	file, err := ParseUnitFile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Sections) != 4 {
+		t.Fatalf("got %d sections, want 4", len(file.Sections))
+	}
+
+	if got := file.Values("Unit", "After"); !slices.Equal(got, []string{"network.target", "db.service"}) {
+		t.Errorf("After = %q", got)
+	}
+
+	if got := file.Values("Service", "ExecStart"); !slices.Equal(got, []string{"/opt/app/app --flag --other"}) {
+		t.Errorf("ExecStart = %q", got)
+	}
+
+	if got := file.Values("Service", "Environment"); !slices.Equal(got, []string{`"C=3 D=4"`}) {
+		t.Errorf("Environment after reset = %q", got)
+	}
+
+	entries := file.Entries("Service")
+
+	if entries[0].Value != "A=1 # not a comment" {
+		t.Errorf("inline # must be part of the value, got %q", entries[0].Value)
+	}
+
+	if entries[1].Line != 7 {
+		t.Errorf("continued entry starts on line %d, want 7", entries[1].Line)
+	}
+
+	if got := file.Keys("Service"); !slices.Equal(got, []string{"Environment", "ExecStart"}) {
+		t.Errorf("Keys = %q", got)
+	}
+
+	again, err := ParseUnitFile(file.Bytes())
+	if err != nil {
+		t.Fatalf("reparse: %v\n%s", err, file.Bytes())
+	}
+
+	if changes := DiffUnits(file, again); len(changes) != 0 {
+		t.Errorf("serialized unit differs: %+v", changes)
+	}
+}
+
+func TestParseUnitFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"Key=value\n":                  "outside of a section",
+		"[Service\n":                   "invalid section header",
+		"[Service]\nnot a directive\n": "missing '='",
+		"[Service]\nBad Key=1\n":       "invalid directive name",
+		"[Service]\nExecStart=a \\":    "unterminated line continuation",
+	}
+
+	for data, want := range cases {
+		_, err := ParseUnitFile([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseUnitFile(%q) = %v, want error containing %q", data, err, want)
+		}
+	}
+}
+
+func TestDiffUnits(t *testing.T) {
+	a, err := ParseUnitFile([]byte("[Service]\nProtectSystem=strict\nPrivateTmp=yes\nEnvironment=A=1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ParseUnitFile([]byte("[Service]\nProtectSystem=full\nEnvironment=A=1\n[Install]\nWantedBy=multi-user.target\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DiffUnits(a, b)
+
+	want := []UnitChange{
+		{Section: "Service", Key: "ProtectSystem", Old: []string{"strict"}, New: []string{"full"}},
+		{Section: "Service", Key: "PrivateTmp", Old: []string{"yes"}},
+		{Section: "Install", Key: "WantedBy", New: []string{"multi-user.target"}},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("got %+v, want %+v", changes, want)
+	}
+
+	for i := range want {
+		if changes[i].Section != want[i].Section || changes[i].Key != want[i].Key || !slices.Equal(changes[i].Old, want[i].Old) || !slices.Equal(changes[i].New, want[i].New) {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	_ "embed"
+	"os"
+	pathpkg "path"
+)
+
+var (
+	//go:embed templates/user-setup.tmpl
+	userSetupStr string
+
+	//go:embed templates/user-uninstall.tmpl
+	userUninstallStr string
+
+	UserSetupTmpl     = parseTemplate("user-setup", userSetupStr)
+	UserUninstallTmpl = parseTemplate("user-uninstall", userUninstallStr)
+)
+
+// systemdUserBackend renders the unit for a per-user service manager. There
+// is no dedicated identity; the service runs as the invoking user inside a
+// user namespace.
+type systemdUserBackend struct{}
+
+func (systemdUserBackend) Name() string {
+	return "systemd-user"
+}
+
+func (systemdUserBackend) Templates() []ArtifactTemplate {
+	return []ArtifactTemplate{
+		{"service", "{name}.service", ServiceTmpl},
+		{"setup", "setup.sh", UserSetupTmpl},
+		{"uninstall", "uninstall.sh", UserUninstallTmpl},
+	}
+}
+
+func (systemdUserBackend) InstalledFiles(cfg *ServiceConfig) map[string]string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+
+	return map[string]string{
+		"unit": pathpkg.Join(dir, "systemd", "user", cfg.Name+".service"),
+	}
+}
+
+func (systemdUserBackend) Unsupported(cfg *ServiceConfig) []string {
+	unsupported := []string{
+		"DevicePolicy=closed (PrivateDevices=yes still hides physical devices)",
+	}
+
+	if cfg.Network && !cfg.Listening {
+		unsupported = append(unsupported, "SocketBindDeny=any (BPF filters need the system manager)")
+	}
+
+	if cfg.LocalhostOnly {
+		unsupported = append(unsupported, "IPAddressAllow=localhost (BPF filters need the system manager)")
+	}
+
+	return unsupported
+}
+
+func (systemdUserBackend) Commands(name, action string) [][]string {
+	switch action {
+	case ActionReload:
+		return [][]string{{"systemctl", "--user", "daemon-reload"}}
+	case ActionRestart:
+		return [][]string{{"systemctl", "--user", "restart", name}}
+	case ActionDisable:
+		return [][]string{{"systemctl", "--user", "disable", "--now", name}}
+	}
+
+	return nil
+}
+
+// UserMode reports whether cfg renders a systemd user service.
+func (cfg *ServiceConfig) UserMode() bool {
+	return cfg.Backend == "systemd-user"
+}